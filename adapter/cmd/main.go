@@ -25,6 +25,10 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "mappings" {
+		os.Exit(runMappingsCommand(cfg, os.Args[2:]))
+	}
+
 	// Initialize database
 	db, err := database.New(cfg.Database.ConnectionString())
 	if err != nil {
@@ -36,6 +40,14 @@ func main() {
 	tokenRepo := repository.NewTokenRepository(db)
 	trustedServiceRepo := repository.NewTrustedServiceRepository(db)
 	fieldMappingRepo := repository.NewFieldMappingRepository(db)
+	lookupTableRepo := repository.NewLookupTableRepository(db)
+	mappingPolicyRepo := repository.NewMappingPolicyRepository(db)
+	exchangeRateRepo := repository.NewExchangeRateRepository(db)
+	mappingParentRepo := repository.NewMappingParentRepository(db)
+	mappingVersionRepo := repository.NewMappingVersionRepository(db)
+	mappingCoverageRepo := repository.NewMappingCoverageRepository(db)
+	mappingSchemaRepo := repository.NewMappingSchemaRepository(db)
+	piiPolicyRepo := repository.NewPIIPolicyRepository(db)
 
 	// Create broker publisher
 	publisher, err := broker.NewPublisher(&cfg.Broker)
@@ -48,13 +60,21 @@ func main() {
 	apiClient := api.NewMercurJSClient(cfg.MercurJS.BaseURL, cfg.MercurJS.ClientID, cfg.MercurJS.ClientSecret, tokenRepo)
 
 	// Create mapper
-	fieldMapper := mapper.New(fieldMappingRepo)
+	mapper.SetHashSalts(cfg.PIISalts)
+	fieldMapper := mapper.New(fieldMappingRepo, lookupTableRepo, mappingPolicyRepo, exchangeRateRepo, mappingParentRepo, mappingSchemaRepo, piiPolicyRepo)
 
 	// Create services
 	webhookService := services.NewWebhookService(cfg.WebhookSecret, publisher, fieldMapper)
 	authService := services.NewAuthService(trustedServiceRepo)
 	consumerService := services.NewConsumerService(authService, apiClient, fieldMapper)
+	bundleService := services.NewBundleService(fieldMappingRepo, fieldMapper)
 	oauthService := services.NewOAuthService(cfg.MercurJS.BaseURL, cfg.MercurJS.ClientID, cfg.MercurJS.ClientSecret, cfg.MercurJS.RedirectURI, tokenRepo)
+	coverageService := services.NewCoverageService(mappingCoverageRepo)
+
+	// Sample mapped payloads for the coverage report
+	coverageService.Start(30 * time.Second)
+	defer coverageService.Close()
+	fieldMapper.SetCoverageObserver(coverageService, cfg.CoverageSampleRate)
 
 	// Create broker consumer
 	consumer, err := broker.NewConsumer(&cfg.Broker, publisher)
@@ -69,10 +89,25 @@ func main() {
 		log.Fatalf("Failed to start consumer: %v", err)
 	}
 
+	// Share mapper cache invalidations with the other adapter instances
+	fieldMapper.SetBroadcaster(publisher, broker.MappingInvalidationTopic)
+	if err := consumer.Subscribe(broker.MappingInvalidationTopic, fieldMapper.HandleInvalidation); err != nil {
+		log.Fatalf("Failed to subscribe to cache invalidations: %v", err)
+	}
+
 	// Create handlers
 	webhookHandler := controllers.NewWebhookHandler(webhookService)
 	oauthHandler := controllers.NewOAuthHandler(oauthService, cfg.WebUIURL)
 	mappingsHandler := controllers.NewMappingsHandler(fieldMappingRepo, fieldMapper)
+	lookupsHandler := controllers.NewLookupsHandler(lookupTableRepo, fieldMapper)
+	policiesHandler := controllers.NewPoliciesHandler(mappingPolicyRepo, fieldMapper)
+	exchangeRatesHandler := controllers.NewExchangeRatesHandler(exchangeRateRepo, fieldMapper)
+	parentsHandler := controllers.NewParentsHandler(mappingParentRepo, fieldMapper)
+	schemasHandler := controllers.NewSchemasHandler(mappingSchemaRepo, fieldMapper)
+	piiPoliciesHandler := controllers.NewPIIPoliciesHandler(piiPolicyRepo, fieldMapper)
+	versionsHandler := controllers.NewVersionsHandler(mappingVersionRepo, fieldMappingRepo, fieldMapper)
+	bundlesHandler := controllers.NewBundlesHandler(bundleService)
+	coverageHandler := controllers.NewCoverageHandler(coverageService)
 
 	// Create API handler for async MQTT requests
 	apiHandler := controllers.NewAPIHandler(publisher, "test-key-789")
@@ -89,7 +124,41 @@ func main() {
 	router.HandleFunc("/oauth/callback", oauthHandler.HandleCallback).Methods("GET")
 	router.HandleFunc("/api/mappings", mappingsHandler.HandleListMappings).Methods("GET")
 	router.HandleFunc("/api/mappings", mappingsHandler.HandleUpsertMapping).Methods("POST")
+	router.HandleFunc("/api/mappings/effective", mappingsHandler.HandleEffectiveMappings).Methods("GET")
+	router.HandleFunc("/api/mappings/preview", mappingsHandler.HandlePreviewMapping).Methods("POST")
+	router.HandleFunc("/api/mappings/lint", mappingsHandler.HandleLintMappings).Methods("GET")
+	router.HandleFunc("/api/mappings/suggest", mappingsHandler.HandleSuggestMappings).Methods("POST")
+	router.HandleFunc("/api/mappings/export", bundlesHandler.HandleExportMappings).Methods("GET")
+	router.HandleFunc("/api/mappings/import", bundlesHandler.HandleImportMappings).Methods("POST")
+	router.HandleFunc("/api/mappings/coverage", coverageHandler.HandleCoverageReport).Methods("GET")
+	router.HandleFunc("/api/mappings/coverage", coverageHandler.HandleResetCoverage).Methods("DELETE")
 	router.HandleFunc("/api/mappings/{id}", mappingsHandler.HandleDeleteMapping).Methods("DELETE")
+	router.HandleFunc("/api/transforms", mappingsHandler.HandleListTransforms).Methods("GET")
+	router.HandleFunc("/api/mapping-versions/{platform_id}/{entity_type}", versionsHandler.HandleListVersions).Methods("GET")
+	router.HandleFunc("/api/mapping-versions/{platform_id}/{entity_type}/diff", versionsHandler.HandleDiff).Methods("GET")
+	router.HandleFunc("/api/mapping-versions/{platform_id}/{entity_type}/publish", versionsHandler.HandlePublish).Methods("POST")
+	router.HandleFunc("/api/mapping-versions/{platform_id}/{entity_type}/rollback", versionsHandler.HandleRollback).Methods("POST")
+	router.HandleFunc("/api/mapping-versions/{platform_id}/{entity_type}/{version:[0-9]+}", versionsHandler.HandleGetVersion).Methods("GET")
+	router.HandleFunc("/api/mapping-parents", parentsHandler.HandleListParents).Methods("GET")
+	router.HandleFunc("/api/mapping-parents", parentsHandler.HandleUpsertParent).Methods("POST")
+	router.HandleFunc("/api/mapping-parents/{platform_id}", parentsHandler.HandleDeleteParent).Methods("DELETE")
+	router.HandleFunc("/api/mapping-schemas", schemasHandler.HandleListSchemas).Methods("GET")
+	router.HandleFunc("/api/mapping-schemas", schemasHandler.HandleUpsertSchema).Methods("POST")
+	router.HandleFunc("/api/mapping-schemas/{platform_id}/{entity_type}/{direction}", schemasHandler.HandleGetSchema).Methods("GET")
+	router.HandleFunc("/api/mapping-schemas/{platform_id}/{entity_type}/{direction}", schemasHandler.HandleDeleteSchema).Methods("DELETE")
+	router.HandleFunc("/api/pii-policies", piiPoliciesHandler.HandleListPIIPolicies).Methods("GET")
+	router.HandleFunc("/api/pii-policies", piiPoliciesHandler.HandleUpsertPIIPolicy).Methods("POST")
+	router.HandleFunc("/api/pii-policies/{platform_id}", piiPoliciesHandler.HandleGetPIIPolicy).Methods("GET")
+	router.HandleFunc("/api/pii-policies/{platform_id}", piiPoliciesHandler.HandleDeletePIIPolicy).Methods("DELETE")
+	router.HandleFunc("/api/mapping-policies", policiesHandler.HandleListPolicies).Methods("GET")
+	router.HandleFunc("/api/mapping-policies", policiesHandler.HandleUpsertPolicy).Methods("POST")
+	router.HandleFunc("/api/mapping-policies/{platform_id}/{entity_type}", policiesHandler.HandleDeletePolicy).Methods("DELETE")
+	router.HandleFunc("/api/lookups", lookupsHandler.HandleListLookups).Methods("GET")
+	router.HandleFunc("/api/lookups", lookupsHandler.HandleUpsertLookup).Methods("POST")
+	router.HandleFunc("/api/lookups/{name}", lookupsHandler.HandleGetLookup).Methods("GET")
+	router.HandleFunc("/api/lookups/{name}", lookupsHandler.HandleDeleteLookup).Methods("DELETE")
+	router.HandleFunc("/api/exchange-rates", exchangeRatesHandler.HandleListRates).Methods("GET")
+	router.HandleFunc("/api/exchange-rates/import", exchangeRatesHandler.HandleImportRates).Methods("POST")
 
 	// API routes (proxied through MQTT)
 	router.HandleFunc("/api/sellers", apiHandler.HandleGetSellers).Methods("GET")
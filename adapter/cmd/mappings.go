@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/config"
+	"github.com/mercurjs/adapter/internal/database"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/repository"
+	"github.com/mercurjs/adapter/internal/services"
+)
+
+const mappingsUsage = `Usage:
+  adapter mappings export [-platform default] [-format yaml|json] [-o file]
+  adapter mappings import [-dry-run] [-prune] <file|->
+`
+
+// runMappingsCommand runs the `mappings` subcommand and returns the exit code
+func runMappingsCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, mappingsUsage)
+		return 2
+	}
+
+	db, err := database.New(cfg.Database.ConnectionString())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// The mapper only lints imports here, which needs the mappings a platform inherits
+	repo := repository.NewFieldMappingRepository(db)
+	fieldMapper := mapper.New(repo, nil, nil, nil, repository.NewMappingParentRepository(db), nil, nil)
+	service := services.NewBundleService(repo, fieldMapper)
+
+	switch args[0] {
+	case "export":
+		err = exportMappings(service, args[1:])
+	case "import":
+		err = importMappings(service, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, mappingsUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func exportMappings(service *services.BundleService, args []string) error {
+	flags := flag.NewFlagSet("mappings export", flag.ContinueOnError)
+	platformID := flags.String("platform", "default", "platform to export")
+	format := flags.String("format", mapper.BundleYAML, "bundle format: yaml or json")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	bundle, err := service.Export(strings.ToLower(strings.TrimSpace(*platformID)))
+	if err != nil {
+		return err
+	}
+	body, err := bundle.Encode(*format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(*output, body, 0o644)
+}
+
+func importMappings(service *services.BundleService, args []string) error {
+	flags := flag.NewFlagSet("mappings import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only print the changes")
+	prune := flags.Bool("prune", false, "delete the platform's mappings missing from the bundle")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one bundle file\n%s", mappingsUsage)
+	}
+
+	var body []byte
+	var err error
+	if path := flags.Arg(0); path == "-" {
+		body, err = io.ReadAll(os.Stdin)
+	} else {
+		body, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	bundle, err := mapper.ParseBundle(body)
+	if err != nil {
+		return err
+	}
+
+	changes, err := service.Import(bundle, *dryRun, *prune)
+	if err != nil {
+		return err
+	}
+
+	printChanges(bundle.PlatformID, changes)
+	if *dryRun {
+		fmt.Println("Dry run: nothing was saved.")
+	} else {
+		fmt.Println("Imported as drafts. Publish each entity type to make the changes live.")
+	}
+	return nil
+}
+
+func printChanges(platformID string, changes map[string]*mapper.MappingDiff) {
+	if len(changes) == 0 {
+		fmt.Printf("%s: no changes\n", platformID)
+		return
+	}
+
+	entityTypes := make([]string, 0, len(changes))
+	for entityType := range changes {
+		entityTypes = append(entityTypes, entityType)
+	}
+	sort.Strings(entityTypes)
+
+	for _, entityType := range entityTypes {
+		diff := changes[entityType]
+		fmt.Printf("%s/%s: %d added, %d changed, %d removed\n", platformID, entityType, len(diff.Added), len(diff.Changed), len(diff.Removed))
+		for _, mapping := range diff.Added {
+			fmt.Printf("  + %s\n", describeMapping(mapping.ShopID, mapping.SourceField, mapping.Condition))
+		}
+		for _, change := range diff.Changed {
+			fmt.Printf("  ~ %s (%s)\n", describeMapping(change.Key.ShopID, change.Key.SourceField, change.Key.Condition), strings.Join(change.Fields, ", "))
+		}
+		for _, mapping := range diff.Removed {
+			fmt.Printf("  - %s\n", describeMapping(mapping.ShopID, mapping.SourceField, mapping.Condition))
+		}
+	}
+}
+
+func describeMapping(shopID, sourceField, condition string) string {
+	s := sourceField
+	if shopID != "" {
+		s = shopID + ": " + s
+	}
+	if condition != "" {
+		s += " if " + condition
+	}
+	return s
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mercurjs/adapter/internal/mapper"
 	"github.com/mercurjs/adapter/internal/models"
 	"github.com/mercurjs/adapter/internal/repository"
 )
@@ -99,7 +100,11 @@ func (c *MercurJSClient) doRequest(method, path, shopID string, body []byte, res
 	}
 
 	// Parse response
-	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := mapper.DecodeJSON(respBody, result); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
@@ -74,6 +74,28 @@ func (p *Publisher) Publish(platform, shopID, eventType string, data map[string]
 	return nil
 }
 
+// PublishQuarantine publishes an event whose mapped data failed validation to
+// its quarantine topic instead of the normal one, with the errors found
+func (p *Publisher) PublishQuarantine(platform, shopID, eventType string, data map[string]interface{}, errors interface{}) error {
+	topic := BuildQuarantineTopic(eventType)
+	msg := &domains.QuarantineMessage{
+		BrokerMessage: *domains.NewBrokerMessage(eventType, platform, shopID, data),
+		Errors:        errors,
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := p.PublishRaw(topic, payload); err != nil {
+		return err
+	}
+
+	log.Printf("[broker] Published to %s", topic)
+	return nil
+}
+
 // PublishRaw publishes raw bytes to a specific topic
 func (p *Publisher) PublishRaw(topic string, payload []byte) error {
 	token := p.client.Publish(topic, 1, false, payload)
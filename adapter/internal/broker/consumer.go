@@ -7,6 +7,7 @@ import (
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/mercurjs/adapter/internal/config"
+	"github.com/mercurjs/adapter/internal/mapper"
 )
 
 // RequestMessage represents incoming request from external services
@@ -28,8 +29,9 @@ type ResponseMessage struct {
 }
 
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // RequestHandler handles a specific action
@@ -86,6 +88,19 @@ func (c *Consumer) Start() error {
 	return nil
 }
 
+// Subscribe delivers the raw payloads published to topic to handler
+func (c *Consumer) Subscribe(topic string, handler func(payload []byte)) error {
+	token := c.client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		handler(msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	log.Printf("[consumer] Subscribed to: %s", topic)
+	return nil
+}
+
 func (c *Consumer) handleMessage(client mqtt.Client, msg mqtt.Message) {
 	log.Printf("[consumer] Received message on topic: %s", msg.Topic())
 
@@ -106,7 +121,7 @@ func (c *Consumer) handleMessage(client mqtt.Client, msg mqtt.Message) {
 
 	// Parse message
 	var req RequestMessage
-	if err := json.Unmarshal(msg.Payload(), &req); err != nil {
+	if err := mapper.DecodeJSON(msg.Payload(), &req); err != nil {
 		log.Printf("[consumer] Failed to parse message: %v", err)
 		c.publishError("", "parse_error", "Failed to parse request message")
 		return
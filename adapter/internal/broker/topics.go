@@ -12,6 +12,16 @@ func BuildTopic(platform, shopID, eventType string) string {
 	return fmt.Sprintf("orders/%s", eventType)
 }
 
+// BuildQuarantineTopic builds the topic of events whose mapped data does not
+// match the platform's schema, e.g. quarantine/order.created
+func BuildQuarantineTopic(eventType string) string {
+	return fmt.Sprintf("quarantine/%s", eventType)
+}
+
+// MappingInvalidationTopic carries mapper cache invalidations between
+// adapter instances
+const MappingInvalidationTopic = "mappings/invalidate"
+
 // ParseTopic parses a topic string back to components
 func ParseTopic(topic string) (eventType string, ok bool) {
 	parts := strings.Split(topic, "/")
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,9 +13,15 @@ type Config struct {
 	Host          string
 	WebhookSecret string
 	WebUIURL      string
-	Broker        BrokerConfig
-	Database      DatabaseConfig
-	MercurJS      MercurJSConfig
+	// CoverageSampleRate is the share of mapped payloads sampled for the
+	// coverage report, between 0 (off, the default) and 1
+	CoverageSampleRate float64
+	Broker             BrokerConfig
+	Database           DatabaseConfig
+	MercurJS           MercurJSConfig
+	// PIISalts are the secrets hash_sha256(salt_ref) keys its hashes with, by
+	// name
+	PIISalts map[string]string
 }
 
 type MercurJSConfig struct {
@@ -52,10 +60,12 @@ func Load() *Config {
 	godotenv.Load()
 
 	return &Config{
-		Port:          getEnv("PORT", "3001"),
-		Host:          getEnv("HOST", "0.0.0.0"),
-		WebhookSecret: getEnv("WEBHOOK_SECRET", ""),
-		WebUIURL:      getEnv("WEBUI_URL", ""),
+		Port:               getEnv("PORT", "3001"),
+		Host:               getEnv("HOST", "0.0.0.0"),
+		WebhookSecret:      getEnv("WEBHOOK_SECRET", ""),
+		WebUIURL:           getEnv("WEBUI_URL", ""),
+		CoverageSampleRate: getEnvFloat("COVERAGE_SAMPLE_RATE", 0),
+		PIISalts:           getEnvMap("PII_SALTS"),
 		Broker: BrokerConfig{
 			URL:      getEnv("BROKER_URL", "tcp://localhost:1883"),
 			ClientID: getEnv("BROKER_CLIENT_ID", "adapter-001"),
@@ -85,3 +95,25 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvMap parses a list of name=value pairs separated by commas
+func getEnvMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if ok && name != "" {
+			values[name] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/services"
+)
+
+type BundlesHandler struct {
+	service *services.BundleService
+}
+
+func NewBundlesHandler(service *services.BundleService) *BundlesHandler {
+	return &BundlesHandler{service: service}
+}
+
+// HandleExportMappings returns a platform's draft mappings as a YAML
+// (default) or JSON bundle
+func (h *BundlesHandler) HandleExportMappings(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("platform_id")))
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+	if format == "" {
+		format = mapper.BundleYAML
+	}
+	if format != mapper.BundleYAML && format != mapper.BundleJSON {
+		http.Error(w, "format must be yaml or json", http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := h.service.Export(platformID)
+	if err != nil {
+		http.Error(w, "Failed to export mappings", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := bundle.Encode(format)
+	if err != nil {
+		http.Error(w, "Failed to encode bundle", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+platformID+`.mappings.`+format+`"`)
+	_, _ = w.Write(body)
+}
+
+// HandleImportMappings loads a YAML or JSON bundle into the drafts of its
+// platform in one transaction. dry_run=true only reports the changes;
+// prune=true also deletes the drafts missing from the bundle.
+func (h *BundlesHandler) HandleImportMappings(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := queryBool(r, "dry_run")
+	if err != nil {
+		http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+		return
+	}
+	prune, err := queryBool(r, "prune")
+	if err != nil {
+		http.Error(w, "prune must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := mapper.ParseBundle(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.service.Import(bundle, dryRun, prune)
+	if errors.Is(err, services.ErrInvalidBundle) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to import mappings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform_id": bundle.PlatformID,
+		"dry_run":     dryRun,
+		"prune":       prune,
+		"count":       len(bundle.Mappings),
+		"changes":     changes,
+	})
+}
+
+// queryBool reads an optional true/false query parameter
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := strings.TrimSpace(r.URL.Query().Get(name))
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
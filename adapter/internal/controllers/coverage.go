@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/services"
+)
+
+type CoverageHandler struct {
+	service *services.CoverageService
+}
+
+func NewCoverageHandler(service *services.CoverageService) *CoverageHandler {
+	return &CoverageHandler{service: service}
+}
+
+// HandleCoverageReport lists, per entity of a platform (or for entity_type),
+// the sampled source paths no mapping reads, with example values and how
+// often they occur
+func (h *CoverageHandler) HandleCoverageReport(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := coverageScope(r)
+
+	reports, err := h.service.Report(platformID, entityType)
+	if err != nil {
+		http.Error(w, "Failed to load coverage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform_id": platformID,
+		"reports":     reports,
+		"count":       len(reports),
+	})
+}
+
+// HandleResetCoverage drops the samples of a platform (or of entity_type), e.g.
+// to measure again after changing its mappings
+func (h *CoverageHandler) HandleResetCoverage(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := coverageScope(r)
+
+	if err := h.service.Reset(platformID, entityType); err != nil {
+		http.Error(w, "Failed to reset coverage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"deleted": true,
+	})
+}
+
+func coverageScope(r *http.Request) (string, string) {
+	platformID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("platform_id")))
+	entityType := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("entity_type")))
+	if platformID == "" {
+		platformID = "default"
+	}
+	return platformID, entityType
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type ExchangeRatesHandler struct {
+	repo   *repository.ExchangeRateRepository
+	mapper *mapper.Mapper
+}
+
+func NewExchangeRatesHandler(repo *repository.ExchangeRateRepository, fieldMapper *mapper.Mapper) *ExchangeRatesHandler {
+	return &ExchangeRatesHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+// exchangeRateColumns is the CSV header expected by HandleImportRates
+var exchangeRateColumns = []string{"base_currency", "quote_currency", "rate", "effective_at"}
+
+func (h *ExchangeRatesHandler) HandleListRates(w http.ResponseWriter, r *http.Request) {
+	base := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("base_currency")))
+	quote := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("quote_currency")))
+
+	rates, err := h.repo.List(base, quote)
+	if err != nil {
+		http.Error(w, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"exchange_rates": rates,
+		"count":          len(rates),
+	})
+}
+
+// HandleImportRates loads rates from a CSV body with the header
+// base_currency,quote_currency,rate,effective_at. The whole file is rejected
+// if any row is invalid.
+func (h *ExchangeRatesHandler) HandleImportRates(w http.ResponseWriter, r *http.Request) {
+	reader := csv.NewReader(r.Body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		http.Error(w, "Invalid CSV body", http.StatusBadRequest)
+		return
+	}
+	for i, column := range exchangeRateColumns {
+		if i >= len(header) || strings.ToLower(strings.TrimSpace(header[i])) != column {
+			http.Error(w, "CSV header must be "+strings.Join(exchangeRateColumns, ","), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var rates []*models.ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Invalid CSV body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rate, err := parseExchangeRate(record)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid exchange rate on line %d: %v", line, err), http.StatusBadRequest)
+			return
+		}
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		http.Error(w, "CSV body has no rates", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Import(rates); err != nil {
+		http.Error(w, "Failed to save exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateRates()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"imported": len(rates),
+	})
+}
+
+func parseExchangeRate(record []string) (*models.ExchangeRate, error) {
+	if len(record) != len(exchangeRateColumns) {
+		return nil, fmt.Errorf("expected %d columns, got %d", len(exchangeRateColumns), len(record))
+	}
+
+	base := strings.ToUpper(strings.TrimSpace(record[0]))
+	quote := strings.ToUpper(strings.TrimSpace(record[1]))
+	if !models.IsValidCurrencyCode(base) || !models.IsValidCurrencyCode(quote) {
+		return nil, fmt.Errorf("currencies must be 3-letter codes")
+	}
+	if base == quote {
+		return nil, fmt.Errorf("base and quote currency are the same")
+	}
+
+	rate := strings.TrimSpace(record[2])
+	if r, ok := new(big.Rat).SetString(rate); !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("rate must be a positive decimal number")
+	}
+
+	effectiveAt, err := parseEffectiveAt(strings.TrimSpace(record[3]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          rate,
+		EffectiveAt:   effectiveAt,
+	}, nil
+}
+
+// parseEffectiveAt accepts RFC3339 timestamps or plain dates (midnight UTC)
+func parseEffectiveAt(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("effective_at must be RFC3339 or YYYY-MM-DD")
+}
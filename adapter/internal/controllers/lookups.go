@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type LookupsHandler struct {
+	repo   *repository.LookupTableRepository
+	mapper *mapper.Mapper
+}
+
+func NewLookupsHandler(repo *repository.LookupTableRepository, fieldMapper *mapper.Mapper) *LookupsHandler {
+	return &LookupsHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+type upsertLookupRequest struct {
+	Name                string  `json:"name"`
+	DefaultValue        *string `json:"default_value"`
+	ReverseDefaultValue *string `json:"reverse_default_value"`
+	Entries             []struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	} `json:"entries"`
+}
+
+func (h *LookupsHandler) HandleListLookups(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.repo.List()
+	if err != nil {
+		http.Error(w, "Failed to load lookup tables", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"lookups": tables,
+		"count":   len(tables),
+	})
+}
+
+func (h *LookupsHandler) HandleGetLookup(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(mux.Vars(r)["name"])
+
+	table, err := h.repo.FindByName(name)
+	if err != nil {
+		http.Error(w, "Failed to load lookup table", http.StatusInternalServerError)
+		return
+	}
+	if table == nil {
+		http.Error(w, "Lookup table not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"lookup": table,
+	})
+}
+
+func (h *LookupsHandler) HandleUpsertLookup(w http.ResponseWriter, r *http.Request) {
+	var req upsertLookupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	table := &models.LookupTable{
+		Name:                name,
+		DefaultValue:        req.DefaultValue,
+		ReverseDefaultValue: req.ReverseDefaultValue,
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range req.Entries {
+		if seen[e.Source] {
+			http.Error(w, "Duplicate source value: "+e.Source, http.StatusBadRequest)
+			return
+		}
+		seen[e.Source] = true
+		table.Entries = append(table.Entries, models.LookupEntry{Source: e.Source, Target: e.Target})
+	}
+
+	row, err := h.repo.Upsert(table)
+	if err != nil {
+		http.Error(w, "Failed to save lookup table", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateLookup(row.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"lookup": row,
+	})
+}
+
+func (h *LookupsHandler) HandleDeleteLookup(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(mux.Vars(r)["name"])
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteByName(name); err != nil {
+		http.Error(w, "Failed to delete lookup table", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateLookup(name)
+	w.WriteHeader(http.StatusNoContent)
+}
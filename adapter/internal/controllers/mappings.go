@@ -24,19 +24,83 @@ func NewMappingsHandler(repo *repository.FieldMappingRepository, fieldMapper *ma
 }
 
 type upsertMappingRequest struct {
-	PlatformID  string `json:"platform_id"`
-	EntityType  string `json:"entity_type"`
-	SourceField string `json:"source_field"`
-	TargetField string `json:"target_field"`
-	Transform   string `json:"transform"`
-	IsActive    *bool  `json:"is_active"`
+	PlatformID   string          `json:"platform_id"`
+	ShopID       string          `json:"shop_id"`
+	EntityType   string          `json:"entity_type"`
+	SourceField  string          `json:"source_field"`
+	TargetField  string          `json:"target_field"`
+	Transform    string          `json:"transform"`
+	SplitPattern string          `json:"split_pattern"`
+	Condition    string          `json:"condition"`
+	DefaultValue json.RawMessage `json:"default_value"`
+	Required     bool            `json:"required"`
+	Unset        bool            `json:"unset"`
+	IsActive     *bool           `json:"is_active"`
+}
+
+// mapping normalizes the request into a FieldMapping
+func (req upsertMappingRequest) mapping() *models.FieldMapping {
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	if platformID == "" {
+		platformID = "default"
+	}
+
+	// default_value is stored as its JSON literal; null means no default
+	defaultValue := strings.TrimSpace(string(req.DefaultValue))
+	if defaultValue == "null" {
+		defaultValue = ""
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	return &models.FieldMapping{
+		PlatformID:   platformID,
+		ShopID:       strings.TrimSpace(req.ShopID),
+		EntityType:   strings.ToLower(strings.TrimSpace(req.EntityType)),
+		SourceField:  strings.TrimSpace(req.SourceField),
+		TargetField:  strings.TrimSpace(req.TargetField),
+		Transform:    strings.TrimSpace(req.Transform),
+		SplitPattern: req.SplitPattern,
+		Condition:    strings.TrimSpace(req.Condition),
+		DefaultValue: defaultValue,
+		Required:     req.Required,
+		Unset:        req.Unset,
+		IsActive:     isActive,
+	}
+}
+
+type previewMappingRequest struct {
+	PlatformID string          `json:"platform_id"`
+	ShopID     string          `json:"shop_id"`
+	EntityType string          `json:"entity_type"`
+	Direction  string          `json:"direction"`
+	Payload    json.RawMessage `json:"payload"`
+	// Mappings, when present, replaces the platform/entity's mappings for
+	// the preview
+	Mappings []upsertMappingRequest `json:"mappings"`
+	// UseDrafts previews the saved drafts instead of the published version
+	UseDrafts bool `json:"use_drafts"`
+}
+
+type suggestMappingsRequest struct {
+	PlatformID string `json:"platform_id"`
+	ShopID     string `json:"shop_id"`
+	EntityType string `json:"entity_type"`
+	// Source is a sample MercurJS payload, Target the same record as the
+	// platform represents it
+	Source json.RawMessage `json:"source"`
+	Target json.RawMessage `json:"target"`
 }
 
 func (h *MappingsHandler) HandleListMappings(w http.ResponseWriter, r *http.Request) {
 	platformID := strings.TrimSpace(r.URL.Query().Get("platform_id"))
+	shopID := strings.TrimSpace(r.URL.Query().Get("shop_id"))
 	entityType := strings.TrimSpace(r.URL.Query().Get("entity_type"))
 
-	mappings, err := h.repo.List(platformID, entityType)
+	mappings, err := h.repo.List(platformID, shopID, entityType)
 	if err != nil {
 		http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
 		return
@@ -56,44 +120,326 @@ func (h *MappingsHandler) HandleUpsertMapping(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	mapping := req.mapping()
+	if mapping.EntityType == "" || mapping.SourceField == "" || (mapping.TargetField == "" && !mapping.Unset) {
+		http.Error(w, "platform_id/entity_type/source_field/target_field are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := mapper.ValidateMapping(mapping); err != nil {
+		http.Error(w, "Invalid mapping: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Lint the drafts as they would be with this mapping saved. Only issues
+	// involving this mapping are reported, so an existing problem elsewhere
+	// does not block unrelated edits.
+	drafts, err := h.loadDrafts(mapping.PlatformID, mapping.ShopID, mapping.EntityType)
+	if err != nil {
+		http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
+		return
+	}
+	issues, err := h.mapper.Lint(mapping.PlatformID, mapping.ShopID, mapping.EntityType, withMapping(drafts, mapping))
+	if err != nil {
+		http.Error(w, "Failed to lint mappings", http.StatusInternalServerError)
+		return
+	}
+
+	key := mapper.MappingKey{ShopID: mapping.ShopID, SourceField: mapping.SourceField, Condition: mapping.Condition}
+	var conflicts []string
+	warnings := []mapper.LintIssue{}
+	for _, issue := range issues {
+		if !issue.Involves(key) {
+			continue
+		}
+		if issue.Severity == mapper.LintError {
+			conflicts = append(conflicts, issue.Message)
+		} else {
+			warnings = append(warnings, issue)
+		}
+	}
+	if len(conflicts) > 0 {
+		http.Error(w, "Mapping conflicts: "+strings.Join(conflicts, "; "), http.StatusBadRequest)
+		return
+	}
+
+	row, err := h.repo.Upsert(mapping)
+	if err != nil {
+		http.Error(w, "Failed to save mapping", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"mapping":  row,
+		"warnings": warnings,
+	})
+}
+
+// HandleLintMappings checks the drafts of a platform/entity (or, with
+// source=published, the published mappings) for conflicts
+func (h *MappingsHandler) HandleLintMappings(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("platform_id")))
+	shopID := strings.TrimSpace(r.URL.Query().Get("shop_id"))
+	entityType := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("entity_type")))
+	source := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("source")))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
+
+	var proposed []*models.FieldMapping
+	switch source {
+	case "", "draft":
+		source = "draft"
+		drafts, err := h.loadDrafts(platformID, shopID, entityType)
+		if err != nil {
+			http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
+			return
+		}
+		proposed = drafts
+	case "published":
+	default:
+		http.Error(w, "source must be draft or published", http.StatusBadRequest)
+		return
+	}
+
+	issues, err := h.mapper.Lint(platformID, shopID, entityType, proposed)
+	if err != nil {
+		http.Error(w, "Failed to lint mappings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == mapper.LintError {
+			errorCount++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform_id": platformID,
+		"shop_id":     shopID,
+		"entity_type": entityType,
+		"source":      source,
+		"issues":      issues,
+		"errors":      errorCount,
+		"warnings":    len(issues) - errorCount,
+	})
+}
+
+// HandleSuggestMappings proposes mappings from a sample MercurJS payload and a
+// sample platform payload, for the fields the drafts do not map yet
+func (h *MappingsHandler) HandleSuggestMappings(w http.ResponseWriter, r *http.Request) {
+	var req suggestMappingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
 	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	shopID := strings.TrimSpace(req.ShopID)
 	entityType := strings.ToLower(strings.TrimSpace(req.EntityType))
-	sourceField := strings.TrimSpace(req.SourceField)
-	targetField := strings.TrimSpace(req.TargetField)
-	transform := strings.TrimSpace(req.Transform)
 
 	if platformID == "" {
 		platformID = "default"
 	}
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
 
-	if entityType == "" || sourceField == "" || targetField == "" {
-		http.Error(w, "platform_id/entity_type/source_field/target_field are required", http.StatusBadRequest)
+	source, ok := decodePayload(req.Source)
+	if !ok {
+		http.Error(w, "source must be a JSON object", http.StatusBadRequest)
+		return
+	}
+	target, ok := decodePayload(req.Target)
+	if !ok {
+		http.Error(w, "target must be a JSON object", http.StatusBadRequest)
 		return
 	}
 
-	isActive := true
-	if req.IsActive != nil {
-		isActive = *req.IsActive
+	drafts, err := h.loadDrafts(platformID, shopID, entityType)
+	if err != nil {
+		http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
+		return
 	}
 
-	row, err := h.repo.Upsert(&models.FieldMapping{
-		PlatformID:  platformID,
-		EntityType:  entityType,
-		SourceField: sourceField,
-		TargetField: targetField,
-		Transform:   transform,
-		IsActive:    isActive,
+	suggestions, err := h.mapper.Suggest(platformID, shopID, entityType, source, target, drafts)
+	if err != nil {
+		http.Error(w, "Failed to suggest mappings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform_id":      platformID,
+		"shop_id":          shopID,
+		"entity_type":      entityType,
+		"mappings":         suggestions.Mappings,
+		"count":            len(suggestions.Mappings),
+		"unmatched_source": suggestions.UnmatchedSource,
+		"unmatched_target": suggestions.UnmatchedTarget,
 	})
+}
+
+// decodePayload decodes a JSON object, keeping numbers exact
+func decodePayload(raw json.RawMessage) (map[string]interface{}, bool) {
+	var payload map[string]interface{}
+	if err := mapper.DecodeJSON(raw, &payload); err != nil || payload == nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// loadDrafts returns the active draft mappings of a platform/entity: the
+// platform-wide ones, plus the shop's when shopID is set. The result is
+// never nil, so it is always used as a proposed set.
+func (h *MappingsHandler) loadDrafts(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
+	drafts := []*models.FieldMapping{}
+	scopes := []string{""}
+	if shopID != "" {
+		scopes = append(scopes, shopID)
+	}
+	for _, scope := range scopes {
+		mappings, err := h.repo.FindByPlatformAndEntity(platformID, scope, entityType)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, mappings...)
+	}
+	return drafts, nil
+}
+
+// withMapping returns drafts with mapping saved: replacing the draft with the
+// same shop, source field and condition, or added at the end
+func withMapping(drafts []*models.FieldMapping, mapping *models.FieldMapping) []*models.FieldMapping {
+	result := make([]*models.FieldMapping, 0, len(drafts)+1)
+	replaced := false
+	for _, draft := range drafts {
+		if draft.ShopID == mapping.ShopID && draft.SourceField == mapping.SourceField && draft.Condition == mapping.Condition {
+			result = append(result, mapping)
+			replaced = true
+			continue
+		}
+		result = append(result, draft)
+	}
+	if !replaced {
+		result = append(result, mapping)
+	}
+	return result
+}
+
+// HandleEffectiveMappings shows the mappings used for a platform/entity after
+// inheritance from parent platforms is resolved
+func (h *MappingsHandler) HandleEffectiveMappings(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("platform_id")))
+	shopID := strings.TrimSpace(r.URL.Query().Get("shop_id"))
+	entityType := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("entity_type")))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
+
+	mappings, err := h.mapper.EffectiveMappings(platformID, shopID, entityType)
 	if err != nil {
-		http.Error(w, "Failed to save mapping", http.StatusInternalServerError)
+		http.Error(w, "Failed to resolve mappings: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	h.mapper.ClearCache()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform_id": platformID,
+		"shop_id":     shopID,
+		"entity_type": entityType,
+		"mappings":    mappings,
+		"count":       len(mappings),
+	})
+}
+
+// HandlePreviewMapping runs a sample payload through the stored, drafted or
+// proposed mappings without saving anything and returns the output with a
+// per-mapping trace
+func (h *MappingsHandler) HandlePreviewMapping(w http.ResponseWriter, r *http.Request) {
+	var req previewMappingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	shopID := strings.TrimSpace(req.ShopID)
+	entityType := strings.ToLower(strings.TrimSpace(req.EntityType))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
+
+	direction := strings.ToLower(strings.TrimSpace(req.Direction))
+	if direction == "" {
+		direction = "forward"
+	}
+	if direction != "forward" && direction != "reverse" {
+		http.Error(w, "direction must be forward or reverse", http.StatusBadRequest)
+		return
+	}
+
+	payload, ok := decodePayload(req.Payload)
+	if !ok {
+		http.Error(w, "payload must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	var proposed []*models.FieldMapping
+	switch {
+	case req.Mappings != nil:
+		proposed = make([]*models.FieldMapping, 0, len(req.Mappings))
+		for _, m := range req.Mappings {
+			mapping := m.mapping()
+			mapping.PlatformID = platformID
+			mapping.EntityType = entityType
+			proposed = append(proposed, mapping)
+		}
+	case req.UseDrafts:
+		drafts, err := h.loadDrafts(platformID, shopID, entityType)
+		if err != nil {
+			http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
+			return
+		}
+		proposed = drafts
+	}
+
+	preview, err := h.mapper.Preview(platformID, shopID, entityType, payload, direction == "reverse", proposed)
+	if err != nil {
+		http.Error(w, "Preview failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"mapping": row,
+		"platform_id": platformID,
+		"shop_id":     shopID,
+		"entity_type": entityType,
+		"direction":   direction,
+		"output":      preview.Output,
+		"trace":       preview.Trace,
+		"skipped":     preview.Skipped,
+		"missing":     preview.Missing,
+		"errors":      preview.Errors,
 	})
 }
 
@@ -109,6 +455,15 @@ func (h *MappingsHandler) HandleDeleteMapping(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	h.mapper.ClearCache()
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *MappingsHandler) HandleListTransforms(w http.ResponseWriter, r *http.Request) {
+	transforms := mapper.Transforms()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"transforms": transforms,
+		"count":      len(transforms),
+	})
+}
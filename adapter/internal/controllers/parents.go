@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type ParentsHandler struct {
+	repo   *repository.MappingParentRepository
+	mapper *mapper.Mapper
+}
+
+func NewParentsHandler(repo *repository.MappingParentRepository, fieldMapper *mapper.Mapper) *ParentsHandler {
+	return &ParentsHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+type upsertParentRequest struct {
+	PlatformID       string `json:"platform_id"`
+	ParentPlatformID string `json:"parent_platform_id"`
+}
+
+func (h *ParentsHandler) HandleListParents(w http.ResponseWriter, r *http.Request) {
+	parents, err := h.repo.List()
+	if err != nil {
+		http.Error(w, "Failed to load mapping parents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"parents": parents,
+		"count":   len(parents),
+	})
+}
+
+func (h *ParentsHandler) HandleUpsertParent(w http.ResponseWriter, r *http.Request) {
+	var req upsertParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	parentID := strings.ToLower(strings.TrimSpace(req.ParentPlatformID))
+
+	if platformID == "" {
+		http.Error(w, "platform_id is required", http.StatusBadRequest)
+		return
+	}
+	if parentID == "" {
+		parentID = "default"
+	}
+
+	// Walk up from the new parent to make sure the chain does not lead back
+	for ancestor := parentID; ancestor != ""; {
+		if ancestor == platformID {
+			http.Error(w, "parent_platform_id would create an inheritance cycle", http.StatusBadRequest)
+			return
+		}
+
+		next, err := h.repo.FindParent(ancestor)
+		if err != nil {
+			http.Error(w, "Failed to load mapping parents", http.StatusInternalServerError)
+			return
+		}
+		ancestor = next
+	}
+
+	row, err := h.repo.Upsert(&models.MappingParent{
+		PlatformID:       platformID,
+		ParentPlatformID: parentID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save mapping parent", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateMappings(platformID, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"parent": row,
+	})
+}
+
+func (h *ParentsHandler) HandleDeleteParent(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.TrimSpace(mux.Vars(r)["platform_id"])
+	if platformID == "" {
+		http.Error(w, "platform_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Delete(platformID); err != nil {
+		http.Error(w, "Failed to delete mapping parent", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateMappings(platformID, "")
+	w.WriteHeader(http.StatusNoContent)
+}
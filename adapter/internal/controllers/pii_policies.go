@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type PIIPoliciesHandler struct {
+	repo   *repository.PIIPolicyRepository
+	mapper *mapper.Mapper
+}
+
+func NewPIIPoliciesHandler(repo *repository.PIIPolicyRepository, fieldMapper *mapper.Mapper) *PIIPoliciesHandler {
+	return &PIIPoliciesHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+type upsertPIIPolicyRequest struct {
+	PlatformID string           `json:"platform_id"`
+	Rules      []piiRuleRequest `json:"rules"`
+}
+
+type piiRuleRequest struct {
+	Path      string `json:"path"`
+	Transform string `json:"transform"`
+}
+
+func (h *PIIPoliciesHandler) HandleListPIIPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.repo.List()
+	if err != nil {
+		http.Error(w, "Failed to load PII policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+		"count":    len(policies),
+	})
+}
+
+func (h *PIIPoliciesHandler) HandleGetPIIPolicy(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.TrimSpace(mux.Vars(r)["platform_id"])
+
+	policy, err := h.repo.Find(platformID)
+	if err != nil {
+		http.Error(w, "Failed to load PII policy", http.StatusInternalServerError)
+		return
+	}
+	if policy == nil {
+		http.Error(w, "PII policy not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy": policy,
+	})
+}
+
+func (h *PIIPoliciesHandler) HandleUpsertPIIPolicy(w http.ResponseWriter, r *http.Request) {
+	var req upsertPIIPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	if platformID == "" {
+		platformID = "default"
+	}
+
+	rules := make([]models.PIIRule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		rules = append(rules, models.PIIRule{
+			Path:      strings.TrimSpace(rule.Path),
+			Transform: strings.TrimSpace(rule.Transform),
+		})
+	}
+
+	if err := mapper.ValidatePIIPolicy(rules); err != nil {
+		http.Error(w, "Invalid PII policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.repo.Upsert(&models.PIIPolicy{
+		PlatformID: platformID,
+		Rules:      rules,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save PII policy", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidatePII(platformID)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy": policy,
+	})
+}
+
+func (h *PIIPoliciesHandler) HandleDeletePIIPolicy(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.TrimSpace(mux.Vars(r)["platform_id"])
+
+	if err := h.repo.Delete(platformID); err != nil {
+		http.Error(w, "Failed to delete PII policy", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidatePII(platformID)
+	w.WriteHeader(http.StatusNoContent)
+}
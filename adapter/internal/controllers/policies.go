@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type PoliciesHandler struct {
+	repo   *repository.MappingPolicyRepository
+	mapper *mapper.Mapper
+}
+
+func NewPoliciesHandler(repo *repository.MappingPolicyRepository, fieldMapper *mapper.Mapper) *PoliciesHandler {
+	return &PoliciesHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+type upsertPolicyRequest struct {
+	PlatformID     string   `json:"platform_id"`
+	EntityType     string   `json:"entity_type"`
+	UnmappedFields string   `json:"unmapped_fields"`
+	ExceptPaths    []string `json:"except_paths"`
+}
+
+func (h *PoliciesHandler) HandleListPolicies(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.TrimSpace(r.URL.Query().Get("platform_id"))
+
+	policies, err := h.repo.List(platformID)
+	if err != nil {
+		http.Error(w, "Failed to load policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+		"count":    len(policies),
+	})
+}
+
+func (h *PoliciesHandler) HandleUpsertPolicy(w http.ResponseWriter, r *http.Request) {
+	var req upsertPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	entityType := strings.ToLower(strings.TrimSpace(req.EntityType))
+	mode := strings.ToLower(strings.TrimSpace(req.UnmappedFields))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
+
+	if !models.IsValidUnmappedMode(mode) {
+		http.Error(w, "unmapped_fields must be one of strict, passthrough, passthrough_except", http.StatusBadRequest)
+		return
+	}
+
+	var exceptPaths []string
+	for _, path := range req.ExceptPaths {
+		if path = strings.TrimSpace(path); path != "" {
+			exceptPaths = append(exceptPaths, path)
+		}
+	}
+
+	if len(exceptPaths) > 0 && mode != models.UnmappedPassthroughExcept {
+		http.Error(w, "except_paths is only used with passthrough_except", http.StatusBadRequest)
+		return
+	}
+
+	row, err := h.repo.Upsert(&models.MappingPolicy{
+		PlatformID:     platformID,
+		EntityType:     entityType,
+		UnmappedFields: mode,
+		ExceptPaths:    exceptPaths,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save policy", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidatePolicy(platformID, entityType)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy": row,
+	})
+}
+
+func (h *PoliciesHandler) HandleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	platformID := strings.TrimSpace(vars["platform_id"])
+	entityType := strings.TrimSpace(vars["entity_type"])
+
+	if err := h.repo.Delete(platformID, entityType); err != nil {
+		http.Error(w, "Failed to delete policy", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidatePolicy(platformID, entityType)
+	w.WriteHeader(http.StatusNoContent)
+}
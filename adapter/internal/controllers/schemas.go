@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+type SchemasHandler struct {
+	repo   *repository.MappingSchemaRepository
+	mapper *mapper.Mapper
+}
+
+func NewSchemasHandler(repo *repository.MappingSchemaRepository, fieldMapper *mapper.Mapper) *SchemasHandler {
+	return &SchemasHandler{
+		repo:   repo,
+		mapper: fieldMapper,
+	}
+}
+
+type upsertSchemaRequest struct {
+	PlatformID string          `json:"platform_id"`
+	EntityType string          `json:"entity_type"`
+	Direction  string          `json:"direction"`
+	Schema     json.RawMessage `json:"schema"`
+}
+
+func (h *SchemasHandler) HandleListSchemas(w http.ResponseWriter, r *http.Request) {
+	platformID := strings.TrimSpace(r.URL.Query().Get("platform_id"))
+
+	schemas, err := h.repo.List(platformID)
+	if err != nil {
+		http.Error(w, "Failed to load schemas", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"schemas": schemas,
+		"count":   len(schemas),
+	})
+}
+
+func (h *SchemasHandler) HandleGetSchema(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	schema, err := h.repo.Find(strings.TrimSpace(vars["platform_id"]), strings.TrimSpace(vars["entity_type"]), strings.TrimSpace(vars["direction"]))
+	if err != nil {
+		http.Error(w, "Failed to load schema", http.StatusInternalServerError)
+		return
+	}
+	if schema == nil {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"schema": schema,
+	})
+}
+
+func (h *SchemasHandler) HandleUpsertSchema(w http.ResponseWriter, r *http.Request) {
+	var req upsertSchemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	platformID := strings.ToLower(strings.TrimSpace(req.PlatformID))
+	entityType := strings.ToLower(strings.TrimSpace(req.EntityType))
+	direction := strings.ToLower(strings.TrimSpace(req.Direction))
+
+	if platformID == "" {
+		platformID = "default"
+	}
+	if direction == "" {
+		direction = models.SchemaForward
+	}
+
+	if entityType == "" {
+		http.Error(w, "entity_type is required", http.StatusBadRequest)
+		return
+	}
+
+	if !models.IsValidSchemaDirection(direction) {
+		http.Error(w, "direction must be forward or reverse", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Schema) == 0 {
+		http.Error(w, "schema is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := mapper.CompileSchema(req.Schema); err != nil {
+		http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	row, err := h.repo.Upsert(&models.MappingSchema{
+		PlatformID: platformID,
+		EntityType: entityType,
+		Direction:  direction,
+		Schema:     req.Schema,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save schema", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateSchema(platformID, entityType)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"schema": row,
+	})
+}
+
+func (h *SchemasHandler) HandleDeleteSchema(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	platformID := strings.TrimSpace(vars["platform_id"])
+	entityType := strings.TrimSpace(vars["entity_type"])
+	direction := strings.TrimSpace(vars["direction"])
+
+	if err := h.repo.Delete(platformID, entityType, direction); err != nil {
+		http.Error(w, "Failed to delete schema", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateSchema(platformID, entityType)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+// draftVersion selects the unpublished field_mappings rows in a diff
+const draftVersion = "draft"
+
+type VersionsHandler struct {
+	repo        *repository.MappingVersionRepository
+	mappingRepo *repository.FieldMappingRepository
+	mapper      *mapper.Mapper
+}
+
+func NewVersionsHandler(repo *repository.MappingVersionRepository, mappingRepo *repository.FieldMappingRepository, fieldMapper *mapper.Mapper) *VersionsHandler {
+	return &VersionsHandler{
+		repo:        repo,
+		mappingRepo: mappingRepo,
+		mapper:      fieldMapper,
+	}
+}
+
+type publishRequest struct {
+	Note string `json:"note"`
+}
+
+type rollbackRequest struct {
+	Version int    `json:"version"`
+	Note    string `json:"note"`
+}
+
+// versionScope reads the platform/entity of a version route
+func versionScope(r *http.Request) (string, string) {
+	vars := mux.Vars(r)
+	return strings.ToLower(strings.TrimSpace(vars["platform_id"])), strings.ToLower(strings.TrimSpace(vars["entity_type"]))
+}
+
+func (h *VersionsHandler) HandleListVersions(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := versionScope(r)
+
+	versions, err := h.repo.List(platformID, entityType)
+	if err != nil {
+		http.Error(w, "Failed to load mapping versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+func (h *VersionsHandler) HandleGetVersion(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := versionScope(r)
+	version, _ := strconv.Atoi(mux.Vars(r)["version"])
+
+	row, err := h.repo.Find(platformID, entityType, version)
+	if err != nil {
+		http.Error(w, "Failed to load mapping version", http.StatusInternalServerError)
+		return
+	}
+	if row == nil {
+		http.Error(w, "Mapping version not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": row,
+	})
+}
+
+// HandlePublish makes the current drafts of a platform/entity the live
+// mappings, unless linting them finds errors
+func (h *VersionsHandler) HandlePublish(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := versionScope(r)
+
+	// The body is optional
+	var req publishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	// Drafts with lint errors would produce wrong output once live
+	drafts, err := h.mappingRepo.List(platformID, "", entityType)
+	if err != nil {
+		http.Error(w, "Failed to load mappings", http.StatusInternalServerError)
+		return
+	}
+	issues, err := h.mapper.LintDrafts(platformID, entityType, drafts)
+	if err != nil {
+		http.Error(w, "Failed to lint mappings", http.StatusInternalServerError)
+		return
+	}
+	if conflicts := mapper.LintErrors(issues); len(conflicts) > 0 {
+		http.Error(w, "Mapping conflicts: "+strings.Join(conflicts, "; "), http.StatusUnprocessableEntity)
+		return
+	}
+
+	row, err := h.repo.Publish(platformID, entityType, strings.TrimSpace(req.Note))
+	if err != nil {
+		http.Error(w, "Failed to publish mappings", http.StatusInternalServerError)
+		return
+	}
+
+	h.mapper.InvalidateMappings(platformID, entityType)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": row,
+	})
+}
+
+// HandleRollback publishes an earlier version again. Drafts are left as they are.
+func (h *VersionsHandler) HandleRollback(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := versionScope(r)
+
+	var req rollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Version <= 0 {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		note = "Rollback to version " + strconv.Itoa(req.Version)
+	}
+
+	row, err := h.repo.Rollback(platformID, entityType, req.Version, note)
+	if err != nil {
+		http.Error(w, "Failed to roll back mappings", http.StatusInternalServerError)
+		return
+	}
+	if row == nil {
+		http.Error(w, "Mapping version not found", http.StatusNotFound)
+		return
+	}
+
+	h.mapper.InvalidateMappings(platformID, entityType)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": row,
+	})
+}
+
+// HandleDiff compares two versions, or a version and the drafts. from
+// defaults to the published version and to defaults to the drafts.
+func (h *VersionsHandler) HandleDiff(w http.ResponseWriter, r *http.Request) {
+	platformID, entityType := versionScope(r)
+
+	versions, err := h.repo.List(platformID, entityType)
+	if err != nil {
+		http.Error(w, "Failed to load mapping versions", http.StatusInternalServerError)
+		return
+	}
+
+	from := strings.TrimSpace(r.URL.Query().Get("from"))
+	to := strings.TrimSpace(r.URL.Query().Get("to"))
+	if from == "" && len(versions) > 0 {
+		from = strconv.Itoa(versions[0].Version)
+	}
+	if to == "" {
+		to = draftVersion
+	}
+
+	fromMappings, status, msg := h.loadMappingSet(platformID, entityType, from)
+	if status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+	toMappings, status, msg := h.loadMappingSet(platformID, entityType, to)
+	if status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"from": from,
+		"to":   to,
+		"diff": mapper.DiffMappings(fromMappings, toMappings),
+	})
+}
+
+// loadMappingSet loads a version number or "draft". An empty version is an
+// empty set, for a platform/entity that was never published. On failure it
+// returns an HTTP status and message.
+func (h *VersionsHandler) loadMappingSet(platformID, entityType, version string) ([]*models.FieldMapping, int, string) {
+	switch version {
+	case "":
+		return nil, 0, ""
+	case draftVersion:
+		mappings, err := h.mappingRepo.List(platformID, "", entityType)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to load mappings"
+		}
+		return mappings, 0, ""
+	}
+
+	n, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, http.StatusBadRequest, "from/to must be a version number or draft"
+	}
+
+	row, err := h.repo.Find(platformID, entityType, n)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load mapping version"
+	}
+	if row == nil {
+		return nil, http.StatusNotFound, "Mapping version " + version + " not found"
+	}
+	return row.Mappings, 0, ""
+}
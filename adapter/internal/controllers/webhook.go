@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 
 	"github.com/mercurjs/adapter/internal/domains"
+	"github.com/mercurjs/adapter/internal/mapper"
 	"github.com/mercurjs/adapter/internal/services"
 )
 
@@ -53,13 +55,34 @@ func (h *WebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Parse payload
 	var payload domains.WebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	if err := mapper.DecodeJSON(body, &payload); err != nil {
 		h.respondError(w, http.StatusBadRequest, "invalid_json", "Failed to parse request body")
 		return
 	}
 
 	// Process webhook
 	if err := h.service.ProcessWebhook(eventType, payload.Data); err != nil {
+		var validationErr *mapper.ValidationError
+		if errors.As(err, &validationErr) {
+			h.respondJSON(w, http.StatusUnprocessableEntity, domains.ErrorResponse{
+				Error:   "validation_failed",
+				Message: err.Error(),
+				Details: validationErr,
+			})
+			return
+		}
+
+		// Quarantined events are accepted, so they are not sent again
+		var schemaErr *mapper.SchemaError
+		if errors.As(err, &schemaErr) {
+			h.respondJSON(w, http.StatusAccepted, domains.ErrorResponse{
+				Error:   "schema_violation",
+				Message: err.Error(),
+				Details: schemaErr,
+			})
+			return
+		}
+
 		log.Printf("[webhook] Failed to process: %v", err)
 		h.respondError(w, http.StatusInternalServerError, "process_failed", err.Error())
 		return
@@ -27,6 +27,12 @@ func New(connectionString string) (*sql.DB, error) {
 
 func migrate(db *sql.DB) error {
 	schema := `
+	-- Data migrations that must run only once, by name
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		name VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT NOW()
+	);
+
 	CREATE TABLE IF NOT EXISTS tokens (
 		id SERIAL PRIMARY KEY,
 		shop_id VARCHAR(100) UNIQUE NOT NULL,
@@ -58,6 +64,117 @@ func migrate(db *sql.DB) error {
 		created_at TIMESTAMP DEFAULT NOW(),
 		UNIQUE(platform_id, entity_type, source_field)
 	);
+
+	ALTER TABLE field_mappings ALTER COLUMN transform TYPE VARCHAR(255);
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS split_pattern TEXT;
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS condition TEXT;
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS default_value TEXT;
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS required BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS unset BOOLEAN NOT NULL DEFAULT false;
+	-- shop_id scopes a mapping to one shop; '' applies platform-wide
+	ALTER TABLE field_mappings ADD COLUMN IF NOT EXISTS shop_id VARCHAR(100) NOT NULL DEFAULT '';
+
+	-- Conditional mappings may share a source_field, so the condition is part of the key
+	ALTER TABLE field_mappings DROP CONSTRAINT IF EXISTS field_mappings_platform_id_entity_type_source_field_key;
+	DROP INDEX IF EXISTS field_mappings_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS field_mappings_scope_key
+		ON field_mappings (platform_id, shop_id, entity_type, source_field, (COALESCE(condition, '')));
+
+	CREATE TABLE IF NOT EXISTS lookup_tables (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name VARCHAR(100) UNIQUE NOT NULL,
+		default_value TEXT,
+		reverse_default_value TEXT,
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS lookup_entries (
+		lookup_table_id UUID NOT NULL REFERENCES lookup_tables(id) ON DELETE CASCADE,
+		position INT NOT NULL,
+		source_value TEXT NOT NULL,
+		target_value TEXT NOT NULL,
+		PRIMARY KEY (lookup_table_id, source_value)
+	);
+
+	CREATE TABLE IF NOT EXISTS mapping_policies (
+		platform_id VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		unmapped_fields VARCHAR(30) NOT NULL DEFAULT 'strict',
+		except_paths TEXT[] DEFAULT '{}',
+		updated_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (platform_id, entity_type)
+	);
+
+	-- JSON Schema the mapped output of a platform/entity must match, per
+	-- direction: forward (Transform) or reverse (ReverseTransform)
+	CREATE TABLE IF NOT EXISTS mapping_schemas (
+		platform_id VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		direction VARCHAR(10) NOT NULL CHECK (direction IN ('forward', 'reverse')),
+		schema JSONB NOT NULL,
+		updated_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (platform_id, entity_type, direction)
+	);
+
+	-- Paths of a platform's Transform output whose values are masked, hashed
+	-- or redacted, as [{"Path": ..., "Transform": ...}]
+	CREATE TABLE IF NOT EXISTS pii_policies (
+		platform_id VARCHAR(50) PRIMARY KEY,
+		rules JSONB NOT NULL DEFAULT '[]',
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS mapping_parents (
+		platform_id VARCHAR(50) PRIMARY KEY,
+		parent_platform_id VARCHAR(50) NOT NULL,
+		updated_at TIMESTAMP DEFAULT NOW(),
+		CHECK (platform_id <> parent_platform_id)
+	);
+
+	-- Published, immutable snapshots of the field_mappings rows (drafts) of a
+	-- platform/entity. The highest version is the one in use.
+	CREATE TABLE IF NOT EXISTS mapping_versions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		platform_id VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		version INT NOT NULL,
+		mappings JSONB NOT NULL,
+		note TEXT,
+		created_at TIMESTAMP DEFAULT NOW(),
+		UNIQUE (platform_id, entity_type, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS exchange_rates (
+		base_currency VARCHAR(3) NOT NULL,
+		quote_currency VARCHAR(3) NOT NULL,
+		rate NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+		effective_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (base_currency, quote_currency, effective_at)
+	);
+
+	-- Source paths seen in sampled Transform payloads, and how often a mapping
+	-- read them, to find the fields a platform's mappings drop
+	CREATE TABLE IF NOT EXISTS mapping_coverage_samples (
+		platform_id VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		samples BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (platform_id, entity_type)
+	);
+
+	CREATE TABLE IF NOT EXISTS mapping_coverage (
+		platform_id VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		path TEXT NOT NULL,
+		seen_count BIGINT NOT NULL DEFAULT 0,
+		mapped_count BIGINT NOT NULL DEFAULT 0,
+		example TEXT NOT NULL DEFAULT '',
+		first_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (platform_id, entity_type, path)
+	);
 	`
 
 	_, err := db.Exec(schema)
@@ -65,6 +182,46 @@ func migrate(db *sql.DB) error {
 		return err
 	}
 
+	// Publish the existing mappings as version 1 when versioning is first
+	// enabled. Later, an empty mapping_versions just means nothing was published.
+	err = runOnce(db, "mapping_versions_initial", `
+		INSERT INTO mapping_versions (platform_id, entity_type, version, mappings, note)
+		SELECT platform_id, entity_type, 1, jsonb_agg(to_jsonb(f) ORDER BY created_at, id), 'Initial version'
+		FROM field_mappings f
+		WHERE NOT EXISTS (SELECT 1 FROM mapping_versions)
+		GROUP BY platform_id, entity_type
+	`)
+	if err != nil {
+		return err
+	}
+
 	log.Println("[database] Migrations applied")
 	return nil
 }
+
+// runOnce runs a data migration and records it in schema_migrations, in one
+// transaction, unless a migration with that name was already recorded
+func runOnce(db *sql.DB, name, query string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, name)
+	if err != nil {
+		return err
+	}
+	applied, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if applied == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
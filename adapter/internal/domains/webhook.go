@@ -29,6 +29,13 @@ func NewBrokerMessage(eventType, platform, shopID string, data map[string]interf
 	}
 }
 
+// QuarantineMessage is an event held back from its topic because its mapped
+// data does not match the platform's schema. Errors lists where.
+type QuarantineMessage struct {
+	BrokerMessage
+	Errors interface{} `json:"errors"`
+}
+
 // WebhookResponse is the response returned to MercurJS
 type WebhookResponse struct {
 	Success bool   `json:"success"`
@@ -37,6 +44,7 @@ type WebhookResponse struct {
 
 // ErrorResponse is the error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Error   string      `json:"error"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
 }
@@ -0,0 +1,235 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Bundle formats
+const (
+	BundleYAML = "yaml"
+	BundleJSON = "json"
+)
+
+// Bundle is a platform's full set of draft mappings, all entities and shops,
+// in a form that can be kept in git
+type Bundle struct {
+	PlatformID string           `json:"platform_id" yaml:"platform_id"`
+	Mappings   []*BundleMapping `json:"mappings" yaml:"mappings"`
+}
+
+// BundleMapping is one mapping of a bundle
+type BundleMapping struct {
+	EntityType   string      `json:"entity_type" yaml:"entity_type"`
+	ShopID       string      `json:"shop_id,omitempty" yaml:"shop_id,omitempty"`
+	SourceField  string      `json:"source_field" yaml:"source_field"`
+	TargetField  string      `json:"target_field,omitempty" yaml:"target_field,omitempty"`
+	Transform    string      `json:"transform,omitempty" yaml:"transform,omitempty"`
+	SplitPattern string      `json:"split_pattern,omitempty" yaml:"split_pattern,omitempty"`
+	Condition    string      `json:"condition,omitempty" yaml:"condition,omitempty"`
+	DefaultValue interface{} `json:"default_value,omitempty" yaml:"default_value,omitempty"`
+	Required     bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Unset        bool        `json:"unset,omitempty" yaml:"unset,omitempty"`
+	// IsActive defaults to true
+	IsActive *bool `json:"is_active,omitempty" yaml:"is_active,omitempty"`
+}
+
+// NewBundle builds the bundle of a platform from its mappings, in order
+func NewBundle(platformID string, mappings []*models.FieldMapping) (*Bundle, error) {
+	bundle := &Bundle{PlatformID: platformID, Mappings: []*BundleMapping{}}
+
+	for _, mapping := range mappings {
+		entry := &BundleMapping{
+			EntityType:   mapping.EntityType,
+			ShopID:       mapping.ShopID,
+			SourceField:  mapping.SourceField,
+			TargetField:  mapping.TargetField,
+			Transform:    mapping.Transform,
+			SplitPattern: mapping.SplitPattern,
+			Condition:    mapping.Condition,
+			Required:     mapping.Required,
+			Unset:        mapping.Unset,
+		}
+		if mapping.DefaultValue != "" {
+			if err := json.Unmarshal([]byte(mapping.DefaultValue), &entry.DefaultValue); err != nil {
+				return nil, fmt.Errorf("mapping %s: invalid default value: %w", mapping.SourceField, err)
+			}
+		}
+		if !mapping.IsActive {
+			inactive := false
+			entry.IsActive = &inactive
+		}
+		bundle.Mappings = append(bundle.Mappings, entry)
+	}
+
+	return bundle, nil
+}
+
+// ParseBundle reads a YAML or JSON bundle. JSON is valid YAML, so one parser
+// handles both.
+func ParseBundle(data []byte) (*Bundle, error) {
+	var bundle Bundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+
+	bundle.PlatformID = strings.ToLower(strings.TrimSpace(bundle.PlatformID))
+	if bundle.PlatformID == "" {
+		return nil, fmt.Errorf("bundle platform_id is required")
+	}
+	return &bundle, nil
+}
+
+// Encode writes the bundle as YAML or JSON
+func (b *Bundle) Encode(format string) ([]byte, error) {
+	switch format {
+	case BundleYAML:
+		return yaml.Marshal(b)
+	case BundleJSON:
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+	return nil, fmt.Errorf("unknown bundle format %q", format)
+}
+
+// FieldMappings validates the bundle and converts it to field mappings of
+// its platform
+func (b *Bundle) FieldMappings() ([]*models.FieldMapping, error) {
+	mappings := make([]*models.FieldMapping, 0, len(b.Mappings))
+	seen := make(map[string]bool, len(b.Mappings))
+
+	for i, entry := range b.Mappings {
+		mapping := &models.FieldMapping{
+			PlatformID:   b.PlatformID,
+			ShopID:       strings.TrimSpace(entry.ShopID),
+			EntityType:   strings.ToLower(strings.TrimSpace(entry.EntityType)),
+			SourceField:  strings.TrimSpace(entry.SourceField),
+			TargetField:  strings.TrimSpace(entry.TargetField),
+			Transform:    strings.TrimSpace(entry.Transform),
+			SplitPattern: entry.SplitPattern,
+			Condition:    strings.TrimSpace(entry.Condition),
+			Required:     entry.Required,
+			Unset:        entry.Unset,
+			IsActive:     entry.IsActive == nil || *entry.IsActive,
+		}
+
+		if mapping.EntityType == "" || mapping.SourceField == "" || (mapping.TargetField == "" && !mapping.Unset) {
+			return nil, fmt.Errorf("mapping %d: entity_type/source_field/target_field are required", i+1)
+		}
+
+		if entry.DefaultValue != nil {
+			defaultValue, err := json.Marshal(entry.DefaultValue)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %d (%s): invalid default value: %w", i+1, mapping.SourceField, err)
+			}
+			mapping.DefaultValue = string(defaultValue)
+		}
+
+		if err := ValidateMapping(mapping); err != nil {
+			return nil, fmt.Errorf("mapping %d (%s): %w", i+1, mapping.SourceField, err)
+		}
+
+		key := mapping.EntityType + "\x00" + mapping.ShopID + "\x00" + mappingKey(mapping)
+		if seen[key] {
+			return nil, fmt.Errorf("mapping %d (%s): duplicate of an earlier mapping with the same entity_type, shop_id, source_field and condition", i+1, mapping.SourceField)
+		}
+		seen[key] = true
+
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+// PlanBundle diffs a platform's stored drafts against the mappings of a
+// bundle, per entity type. Without prune, stored mappings missing from the
+// bundle are kept, so they are not reported as removed. With prune, every
+// stored mapping missing from the bundle is removed, including those of
+// entity types and shops the bundle does not mention.
+func PlanBundle(current, desired []*models.FieldMapping, prune bool) map[string]*MappingDiff {
+	before := make(map[string][]*models.FieldMapping)
+	for _, mapping := range current {
+		// Compare default values in the form the bundle writes them
+		normalized := *mapping
+		normalized.DefaultValue = normalizeDefaultValue(mapping.DefaultValue)
+		before[mapping.EntityType] = append(before[mapping.EntityType], &normalized)
+	}
+
+	after := make(map[string][]*models.FieldMapping)
+	for _, mapping := range desired {
+		after[mapping.EntityType] = append(after[mapping.EntityType], mapping)
+	}
+
+	plan := make(map[string]*MappingDiff)
+	for entityType := range before {
+		if _, ok := after[entityType]; !ok {
+			after[entityType] = nil
+		}
+	}
+	for entityType, mappings := range after {
+		diff := DiffMappings(before[entityType], mappings)
+		if !prune {
+			diff.Removed = []*models.FieldMapping{}
+		}
+		if !diff.Empty() {
+			plan[entityType] = diff
+		}
+	}
+	return plan
+}
+
+// ImportedMappings returns the drafts of each entity type of a bundle as
+// they are after importing it: the stored ones updated by the bundle and the
+// bundle's new mappings after them. With prune the stored mappings missing
+// from the bundle are left out, so entity types the bundle drops have no
+// drafts left and are not returned.
+func ImportedMappings(current, desired []*models.FieldMapping, prune bool) map[string][]*models.FieldMapping {
+	entityTypes := make(map[string]bool)
+	incoming := make(map[string]*models.FieldMapping, len(desired))
+	for _, mapping := range desired {
+		entityTypes[mapping.EntityType] = true
+		incoming[mapping.EntityType+"\x00"+mapping.ShopID+"\x00"+mappingKey(mapping)] = mapping
+	}
+
+	result := make(map[string][]*models.FieldMapping)
+	used := make(map[*models.FieldMapping]bool, len(desired))
+	for _, mapping := range current {
+		if replacement, ok := incoming[mapping.EntityType+"\x00"+mapping.ShopID+"\x00"+mappingKey(mapping)]; ok {
+			result[mapping.EntityType] = append(result[mapping.EntityType], replacement)
+			used[replacement] = true
+			continue
+		}
+		if prune || !entityTypes[mapping.EntityType] {
+			continue
+		}
+		result[mapping.EntityType] = append(result[mapping.EntityType], mapping)
+	}
+	for _, mapping := range desired {
+		if !used[mapping] {
+			result[mapping.EntityType] = append(result[mapping.EntityType], mapping)
+		}
+	}
+	return result
+}
+
+func normalizeDefaultValue(literal string) string {
+	if literal == "" {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(literal), &value); err != nil {
+		return literal
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return literal
+	}
+	return string(normalized)
+}
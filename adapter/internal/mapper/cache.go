@@ -0,0 +1,221 @@
+package mapper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// Kinds of cache invalidation
+const (
+	InvalidateMappings = "mappings"
+	InvalidatePolicy   = "policy"
+	InvalidateLookup   = "lookup"
+	InvalidateRates    = "rates"
+	InvalidateSchema   = "schema"
+	InvalidatePII      = "pii"
+	InvalidateAll      = "all"
+)
+
+// Invalidation names the cache entries made stale by a change. It is applied
+// locally and broadcast to the other adapter instances.
+type Invalidation struct {
+	// Origin is the instance that made the change
+	Origin string `json:"origin"`
+	Kind   string `json:"kind"`
+	// PlatformID and EntityType scope mappings, policy and schema
+	// invalidations; an empty EntityType covers every entity of the platform.
+	// PII invalidations only use PlatformID.
+	PlatformID string `json:"platform_id,omitempty"`
+	EntityType string `json:"entity_type,omitempty"`
+	// Name is the lookup table for lookup invalidations
+	Name string `json:"name,omitempty"`
+}
+
+// Broadcaster publishes invalidations to the other adapter instances.
+// *broker.Publisher satisfies it.
+type Broadcaster interface {
+	PublishRaw(topic string, payload []byte) error
+}
+
+type cachedMappings struct {
+	mappings   []*models.FieldMapping
+	plan       *mappingPlan
+	entityType string
+	// platforms are the platform and its parent chain the mappings were
+	// resolved from; a change to any of them makes the entry stale
+	platforms []string
+	fetchedAt time.Time
+}
+
+type cachedLookup struct {
+	table     *models.LookupTable
+	fetchedAt time.Time
+}
+
+type cachedPolicy struct {
+	policy    *models.MappingPolicy
+	fetchedAt time.Time
+}
+
+type cachedPIIPolicy struct {
+	rules     []*compiledPIIRule
+	fetchedAt time.Time
+}
+
+type cachedSchema struct {
+	schema    *Schema
+	err       error
+	fetchedAt time.Time
+}
+
+// SetBroadcaster makes the mapper publish its invalidations to topic
+func (m *Mapper) SetBroadcaster(b Broadcaster, topic string) {
+	m.mu.Lock()
+	m.broadcaster = b
+	m.broadcastTopic = topic
+	m.mu.Unlock()
+}
+
+// InvalidateMappings drops the cached mappings of a platform/entity, including
+// those of its shops and of the platforms inheriting from it. An empty
+// entityType drops every entity of the platform.
+func (m *Mapper) InvalidateMappings(platformID, entityType string) {
+	m.invalidate(Invalidation{Kind: InvalidateMappings, PlatformID: platformID, EntityType: entityType})
+}
+
+// InvalidatePolicy drops the cached unmapped field policy of a platform/entity
+func (m *Mapper) InvalidatePolicy(platformID, entityType string) {
+	m.invalidate(Invalidation{Kind: InvalidatePolicy, PlatformID: platformID, EntityType: entityType})
+}
+
+// InvalidateSchema drops the cached output schemas of a platform/entity, in
+// both directions
+func (m *Mapper) InvalidateSchema(platformID, entityType string) {
+	m.invalidate(Invalidation{Kind: InvalidateSchema, PlatformID: platformID, EntityType: entityType})
+}
+
+// InvalidatePII drops the cached PII policy of a platform
+func (m *Mapper) InvalidatePII(platformID string) {
+	m.invalidate(Invalidation{Kind: InvalidatePII, PlatformID: platformID})
+}
+
+// InvalidateLookup drops a cached lookup table
+func (m *Mapper) InvalidateLookup(name string) {
+	m.invalidate(Invalidation{Kind: InvalidateLookup, Name: name})
+}
+
+// InvalidateRates drops the cached exchange rates
+func (m *Mapper) InvalidateRates() {
+	m.invalidate(Invalidation{Kind: InvalidateRates})
+}
+
+// ClearCache clears the mapping, lookup table, policy, PII policy, schema and
+// exchange rate caches
+func (m *Mapper) ClearCache() {
+	m.invalidate(Invalidation{Kind: InvalidateAll})
+}
+
+// HandleInvalidation applies an invalidation broadcast by another instance
+func (m *Mapper) HandleInvalidation(payload []byte) {
+	var inv Invalidation
+	if err := json.Unmarshal(payload, &inv); err != nil {
+		log.Printf("[mapper] Invalid cache invalidation: %v", err)
+		return
+	}
+	if inv.Origin == m.instanceID {
+		return
+	}
+	m.evict(inv)
+}
+
+func (m *Mapper) invalidate(inv Invalidation) {
+	inv.Origin = m.instanceID
+	m.evict(inv)
+
+	m.mu.RLock()
+	b, topic := m.broadcaster, m.broadcastTopic
+	m.mu.RUnlock()
+	if b == nil {
+		return
+	}
+
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		log.Printf("[mapper] Failed to encode cache invalidation: %v", err)
+		return
+	}
+	// Other instances still pick the change up once their entries expire
+	if err := b.PublishRaw(topic, payload); err != nil {
+		log.Printf("[mapper] Failed to broadcast cache invalidation: %v", err)
+	}
+}
+
+// evict drops the entries an invalidation covers. Loads that started before
+// it are not cached, so they cannot put a stale entry back.
+func (m *Mapper) evict(inv Invalidation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generation++
+
+	switch inv.Kind {
+	case InvalidateMappings:
+		for key, entry := range m.cache {
+			if inv.EntityType != "" && entry.entityType != inv.EntityType {
+				continue
+			}
+			for _, platformID := range entry.platforms {
+				if platformID == inv.PlatformID {
+					delete(m.cache, key)
+					break
+				}
+			}
+		}
+	case InvalidatePolicy:
+		prefix := inv.PlatformID + ":"
+		for key := range m.policyCache {
+			if key == prefix+inv.EntityType || (inv.EntityType == "" && strings.HasPrefix(key, prefix)) {
+				delete(m.policyCache, key)
+			}
+		}
+	case InvalidateSchema:
+		prefix := inv.PlatformID + ":"
+		for key := range m.schemaCache {
+			if strings.HasPrefix(key, prefix+inv.EntityType+":") || (inv.EntityType == "" && strings.HasPrefix(key, prefix)) {
+				delete(m.schemaCache, key)
+			}
+		}
+	case InvalidatePII:
+		delete(m.piiCache, inv.PlatformID)
+	case InvalidateLookup:
+		delete(m.lookupCache, inv.Name)
+	case InvalidateRates:
+		m.rateCache = make(map[string]cachedRate)
+	default:
+		m.cache = make(map[string]*cachedMappings)
+		m.lookupCache = make(map[string]*cachedLookup)
+		m.policyCache = make(map[string]*cachedPolicy)
+		m.schemaCache = make(map[string]*cachedSchema)
+		m.piiCache = make(map[string]*cachedPIIPolicy)
+		m.rateCache = make(map[string]cachedRate)
+	}
+}
+
+// fresh reports whether an entry loaded at fetchedAt is within the TTL
+func (m *Mapper) fresh(fetchedAt time.Time) bool {
+	return time.Since(fetchedAt) < m.ttl
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
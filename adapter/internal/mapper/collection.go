@@ -0,0 +1,220 @@
+package mapper
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// stepFilter returns the condition the filter step compiled, or parses it
+// when the transform runs outside a compiled pipeline
+func stepFilter(ctx *Context, args []string) (condition, error) {
+	if cond, ok := ctx.Compiled().(condition); ok {
+		return cond, nil
+	}
+	return parseCondition(args[0])
+}
+
+// Collection transforms take a whole array, e.g. the values collected by a
+// `items.*.quantity` -> `total_quantity` mapping, or an `items` array of
+// objects. Paths in their arguments are relative to each element; scalar
+// elements are available as `value`.
+func init() {
+	Register("sum", &builtin{
+		signature:   "sum(path?)",
+		description: "Adds up the numbers of an array, or the numbers at path in each element, exactly. Not reversed",
+		maxArgs:     1,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			total := new(big.Rat)
+			for _, elem := range elementValues(value, args) {
+				if r, ok := toDecimal(elem); ok {
+					total.Add(total, r)
+				}
+			}
+			return fromDecimal(total)
+		}),
+		inverse:  discard,
+		validate: validateElementPathArg,
+		lossy:    alwaysLossy,
+		list:     true,
+	})
+
+	Register("count", &builtin{
+		signature:   "count",
+		description: "Returns the number of elements of an array. Not reversed",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if list, ok := value.([]interface{}); ok {
+				return len(list)
+			}
+			return 1
+		}),
+		inverse: discard,
+		lossy:   alwaysLossy,
+		list:    true,
+	})
+
+	Register("first", &builtin{
+		signature:   "first",
+		description: "Returns the first non-null element of an array; the inverse wraps a value in a one-element array (lossy)",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			list, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+			for _, elem := range list {
+				if elem != nil {
+					return elem
+				}
+			}
+			return nil
+		}),
+		inverse: scalar(func(value interface{}, args []string) interface{} {
+			if _, ok := value.([]interface{}); ok {
+				return value
+			}
+			return []interface{}{value}
+		}),
+		lossy: alwaysLossy,
+		list:  true,
+	})
+
+	Register("filter", &builtin{
+		signature:   "filter(condition)",
+		description: `Keeps the elements of an array matching a condition, e.g. filter(status == "active") or filter(value != "") (lossy)`,
+		minArgs:     1,
+		maxArgs:     1,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			list, ok := value.([]interface{})
+			if !ok {
+				return value, nil
+			}
+			cond, err := stepFilter(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+
+			kept := make([]interface{}, 0, len(list))
+			for _, elem := range list {
+				if elem != nil && truthy(cond.eval(elementDoc(elem))) {
+					kept = append(kept, elem)
+				}
+			}
+			return kept, nil
+		},
+		inverse: identity,
+		validate: func(args []string) error {
+			_, err := parseCondition(args[0])
+			return err
+		},
+		compile: func(args []string) (interface{}, error) {
+			return parseCondition(args[0])
+		},
+		lossy: alwaysLossy,
+		list:  true,
+	})
+
+	Register("sort_by", &builtin{
+		signature:   "sort_by(path, order?)",
+		description: "Sorts an array by the value at path in each element, asc (default) or desc. Elements without a value go last (lossy)",
+		minArgs:     1,
+		maxArgs:     2,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			list, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+			return sortBy(list, compilePath(args[0]), len(args) > 1 && args[1] == "desc")
+		}),
+		inverse: identity,
+		validate: func(args []string) error {
+			if err := validatePath(args[0]); err != nil {
+				return err
+			}
+			if len(args) > 1 && args[1] != "asc" && args[1] != "desc" {
+				return fmt.Errorf("order must be asc or desc")
+			}
+			return nil
+		},
+		lossy: alwaysLossy,
+		list:  true,
+	})
+}
+
+// discard is the inverse of aggregates, which cannot be reversed: a nil
+// result writes nothing
+func discard(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	return nil, nil
+}
+
+func validateElementPathArg(args []string) error {
+	if len(args) > 0 {
+		return validatePath(args[0])
+	}
+	return nil
+}
+
+// elementDoc returns the document paths of an element are resolved against:
+// the element itself for objects, {"value": elem} for anything else
+func elementDoc(elem interface{}) map[string]interface{} {
+	if obj, ok := elem.(map[string]interface{}); ok {
+		return obj
+	}
+	return map[string]interface{}{"value": elem}
+}
+
+// elementValues returns the elements of an array value, or with a path in
+// args, the non-nil values at that path in each element. The path may contain
+// wildcards. A value that is not an array is treated as a one-element array.
+func elementValues(value interface{}, args []string) []interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	if len(args) == 0 {
+		return list
+	}
+
+	path := compilePath(args[0])
+	var values []interface{}
+	for _, elem := range list {
+		_ = path.each(elementDoc(elem), func(_ []int, v interface{}) error {
+			values = append(values, v)
+			return nil
+		})
+	}
+	return values
+}
+
+// sortBy returns a sorted copy of list. The sort is stable, so elements with
+// equal or incomparable values keep their order.
+func sortBy(list []interface{}, path fieldPath, desc bool) []interface{} {
+	keys := make([]interface{}, len(list))
+	order := make([]int, len(list))
+	for i, elem := range list {
+		order[i] = i
+		if elem != nil {
+			keys[i] = path.get(elementDoc(elem))
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		if ka == nil || kb == nil {
+			return ka != nil
+		}
+		cmp, ok := compareValues(ka, kb)
+		if !ok {
+			return false
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	sorted := make([]interface{}, len(list))
+	for i, idx := range order {
+		sorted[i] = list[idx]
+	}
+	return sorted
+}
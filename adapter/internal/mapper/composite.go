@@ -0,0 +1,176 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fieldTemplate renders one target value from several source paths, e.g.
+// "{shipping_address.first_name} {shipping_address.last_name}"
+type fieldTemplate struct {
+	// literals has one more element than paths: literals[i] precedes paths[i]
+	literals []string
+	paths    []string
+	fields   []fieldPath
+	pattern  *regexp.Regexp
+}
+
+func isTemplate(field string) bool {
+	return strings.ContainsAny(field, "{}")
+}
+
+func parseTemplate(s string) (*fieldTemplate, error) {
+	tpl := &fieldTemplate{}
+	rest := s
+
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open == -1 {
+			if strings.ContainsRune(rest, '}') {
+				return nil, fmt.Errorf("unmatched '}' in template %q", s)
+			}
+			tpl.literals = append(tpl.literals, rest)
+			break
+		}
+
+		literal := rest[:open]
+		if strings.ContainsRune(literal, '}') {
+			return nil, fmt.Errorf("unmatched '}' in template %q", s)
+		}
+		if literal == "" && len(tpl.paths) > 0 {
+			return nil, fmt.Errorf("placeholders in template %q must be separated by text", s)
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("unclosed '{' in template %q", s)
+		}
+
+		path := strings.TrimSpace(rest[open+1 : open+end])
+		if err := validatePath(path); err != nil {
+			return nil, err
+		}
+		if countWildcards(path) > 0 {
+			return nil, fmt.Errorf("wildcards are not supported in template %q", s)
+		}
+
+		tpl.literals = append(tpl.literals, literal)
+		tpl.paths = append(tpl.paths, path)
+		tpl.fields = append(tpl.fields, compilePath(path))
+		rest = rest[open+end+1:]
+	}
+
+	if len(tpl.paths) == 0 {
+		return nil, fmt.Errorf("template %q has no placeholders", s)
+	}
+
+	// Build the pattern used to parse rendered values back into their parts
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := range tpl.paths {
+		pattern.WriteString(regexp.QuoteMeta(tpl.literals[i]))
+		pattern.WriteString("(.*?)")
+	}
+	pattern.WriteString(regexp.QuoteMeta(tpl.literals[len(tpl.literals)-1]))
+	pattern.WriteString("$")
+	tpl.pattern = regexp.MustCompile(pattern.String())
+
+	return tpl, nil
+}
+
+// render fills the placeholders from doc. It reports false if any of them is
+// missing.
+func (t *fieldTemplate) render(doc map[string]interface{}) (string, bool) {
+	var out strings.Builder
+	for i, field := range t.fields {
+		value := field.get(doc)
+		if value == nil {
+			return "", false
+		}
+		out.WriteString(t.literals[i])
+		out.WriteString(toString(value))
+	}
+	out.WriteString(t.literals[len(t.literals)-1])
+	return out.String(), true
+}
+
+// parse splits a rendered value back into its placeholder values
+func (t *fieldTemplate) parse(value string) (map[string]string, bool) {
+	groups := t.pattern.FindStringSubmatch(value)
+	if groups == nil {
+		return nil, false
+	}
+
+	values := make(map[string]string, len(t.paths))
+	for i, path := range t.paths {
+		values[path] = groups[i+1]
+	}
+	return values, true
+}
+
+// fieldSplitter fans one source value out into several targets, either on a
+// literal separator or by the capture groups of a /regex/
+type fieldSplitter struct {
+	targets   []string
+	fields    []fieldPath
+	separator string
+	pattern   *regexp.Regexp
+}
+
+func parseSplit(spec, targetField string) (*fieldSplitter, error) {
+	splitter := &fieldSplitter{}
+
+	for _, target := range strings.Split(targetField, ",") {
+		target = strings.TrimSpace(target)
+		if err := validatePath(target); err != nil {
+			return nil, err
+		}
+		if countWildcards(target) > 0 {
+			return nil, fmt.Errorf("wildcards are not supported in split target %q", target)
+		}
+		splitter.targets = append(splitter.targets, target)
+		splitter.fields = append(splitter.fields, compilePath(target))
+	}
+
+	if len(spec) >= 2 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+		pattern, err := regexp.Compile(spec[1 : len(spec)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid split pattern: %w", err)
+		}
+		if pattern.NumSubexp() != len(splitter.targets) {
+			return nil, fmt.Errorf("split pattern has %d group(s) but there are %d target(s)", pattern.NumSubexp(), len(splitter.targets))
+		}
+		splitter.pattern = pattern
+		return splitter, nil
+	}
+
+	if spec == "" {
+		return nil, fmt.Errorf("split separator must not be empty")
+	}
+	splitter.separator = spec
+	return splitter, nil
+}
+
+// split returns the parts of value in target order
+func (s *fieldSplitter) split(value string) []string {
+	if s.pattern == nil {
+		return strings.SplitN(value, s.separator, len(s.targets))
+	}
+
+	groups := s.pattern.FindStringSubmatch(value)
+	if groups == nil {
+		return nil
+	}
+	return groups[1:]
+}
+
+// invertible reports whether the parts can be joined back into the source
+// value. Regex splits drop the text between groups, so they cannot.
+func (s *fieldSplitter) invertible() bool {
+	return s.pattern == nil
+}
+
+func (s *fieldSplitter) join(parts []string) string {
+	return strings.Join(parts, s.separator)
+}
@@ -0,0 +1,395 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// condition is a parsed predicate such as `type == "marketplace" && currency != "USD"`
+// that is evaluated against a whole source document
+type condition interface {
+	eval(doc map[string]interface{}) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type pathNode struct {
+	path fieldPath
+}
+
+type notNode struct {
+	operand condition
+}
+
+type logicalNode struct {
+	op          string
+	left, right condition
+}
+
+type compareNode struct {
+	op          string
+	left, right condition
+}
+
+func (n literalNode) eval(doc map[string]interface{}) interface{} {
+	return n.value
+}
+
+func (n pathNode) eval(doc map[string]interface{}) interface{} {
+	return n.path.get(doc)
+}
+
+func (n notNode) eval(doc map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(doc))
+}
+
+func (n logicalNode) eval(doc map[string]interface{}) interface{} {
+	left := truthy(n.left.eval(doc))
+	if n.op == "&&" {
+		return left && truthy(n.right.eval(doc))
+	}
+	return left || truthy(n.right.eval(doc))
+}
+
+func (n compareNode) eval(doc map[string]interface{}) interface{} {
+	left := n.left.eval(doc)
+	right := n.right.eval(doc)
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	}
+
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// ValidateCondition checks that a condition expression is well formed
+func ValidateCondition(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	_, err := parseCondition(expr)
+	return err
+}
+
+func parseCondition(expr string) (condition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in condition", p.tokens[p.pos].text)
+	}
+	return cond, nil
+}
+
+type tokenKind int
+
+const (
+	tokenPath tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type conditionToken struct {
+	kind tokenKind
+	text string
+}
+
+var conditionOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string in condition")
+			}
+			tokens = append(tokens, conditionToken{kind: tokenString, text: expr[i : end+1]})
+			i = end + 1
+			continue
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(expr) && (expr[end] == '.' || (expr[end] >= '0' && expr[end] <= '9')) {
+				end++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenNumber, text: expr[i:end]})
+			i = end
+			continue
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			end := i + 1
+			for end < len(expr) && isPathChar(expr[end]) {
+				end++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenPath, text: expr[i:end]})
+			i = end
+			continue
+		}
+
+		matched := false
+		for _, op := range conditionOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, conditionToken{kind: tokenOperator, text: op})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected character %q in condition", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isPathChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+func (p *conditionParser) peekOperator(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *conditionParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOperator("||"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *conditionParser) parseAnd() (condition, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOperator("&&"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *conditionParser) parseUnary() (condition, error) {
+	if _, ok := p.peekOperator("!"); ok {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (condition, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.peekOperator("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *conditionParser) parseOperand() (condition, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokenString:
+		s, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s in condition", tok.text)
+		}
+		return literalNode{value: s}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s in condition", tok.text)
+		}
+		return literalNode{value: f}, nil
+	case tokenPath:
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		return pathNode{path: compilePath(tok.text)}, nil
+	}
+
+	if tok.text == "(" {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.peekOperator(")"); !ok {
+			return nil, fmt.Errorf("missing closing parenthesis in condition")
+		}
+		p.pos++
+		return inner, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q in condition", tok.text)
+}
+
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	default:
+		if f, ok := toFloat(v); ok {
+			return f != 0
+		}
+		return true
+	}
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+
+	switch va := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		vb, ok := b.(string)
+		return ok && va == vb
+	case bool:
+		vb, ok := b.(bool)
+		return ok && va == vb
+	}
+	return false
+}
+
+// compareValues orders two numbers or two strings
+func compareValues(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	sa, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	sb, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
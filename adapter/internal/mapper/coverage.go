@@ -0,0 +1,177 @@
+package mapper
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// CoverageObserver receives a sample of the payloads Transform maps, e.g. to
+// find the source fields of a new platform that no mapping uses yet
+type CoverageObserver interface {
+	ObserveCoverage(sample *CoverageSample)
+}
+
+// CoverageSample is one payload seen by Transform, flattened into its paths
+type CoverageSample struct {
+	PlatformID string
+	EntityType string
+	Paths      []ObservedPath
+}
+
+// ObservedPath is a leaf path of a payload, with array indexes written as
+// `*`, e.g. `items.*.sku`
+type ObservedPath struct {
+	Path string
+	// Mapped is set when a mapping read the path or one of its parents
+	Mapped bool
+	// Example is the first value found at the path, or Redacted when a
+	// mapping writes its values where the PII policy applies
+	Example interface{}
+}
+
+// SetCoverageObserver makes Transform pass a share of its payloads, between
+// 0 and 1, to o. Nested entities, ReverseTransform and previews are not
+// sampled.
+func (m *Mapper) SetCoverageObserver(o CoverageObserver, sampleRate float64) {
+	m.mu.Lock()
+	m.coverage = o
+	m.coverageRate = sampleRate
+	m.mu.Unlock()
+}
+
+// observeCoverage samples a payload mapped by Transform with plan, nil when
+// the entity has no mappings. consumed are the input paths the mappings read.
+func (m *Mapper) observeCoverage(scope mappingScope, plan *mappingPlan, data map[string]interface{}, consumed []string) {
+	m.mu.RLock()
+	o, rate := m.coverage, m.coverageRate
+	m.mu.RUnlock()
+
+	if o == nil || len(scope.entities) > 1 || rate <= 0 || rand.Float64() >= rate {
+		return
+	}
+
+	// Examples are stored, so they go through the PII policy too. Its rules
+	// are written against output paths: they mask values passed through
+	// unchanged, and the input paths mapped to a path they cover keep no
+	// example at all.
+	rules, err := m.getPIIRules(scope.platformID)
+	if err != nil {
+		return
+	}
+	data, err = m.applyPIIPolicy(scope.platformID, data)
+	if err != nil {
+		return
+	}
+
+	o.ObserveCoverage(&CoverageSample{
+		PlatformID: scope.platformID,
+		EntityType: scope.entityType(),
+		Paths:      observePaths(data, consumed, piiSources(plan, rules)),
+	})
+}
+
+// piiSources returns the input paths of the mappings that write a path a PII
+// rule covers, or a parent or child of one
+func piiSources(plan *mappingPlan, rules []*compiledPIIRule) []string {
+	if plan == nil || len(rules) == 0 {
+		return nil
+	}
+
+	var sources []string
+	for _, c := range plan.mappings {
+		if c.mapping.Unset || !writesPII(c.mapping, rules) {
+			continue
+		}
+		if c.template != nil {
+			sources = append(sources, c.template.paths...)
+		} else {
+			sources = append(sources, c.mapping.SourceField)
+		}
+	}
+	return sources
+}
+
+func writesPII(mapping *models.FieldMapping, rules []*compiledPIIRule) bool {
+	for _, target := range forwardPaths(mapping) {
+		target = normalizeIndexes(strings.Split(target, "."))
+		for _, rule := range rules {
+			path := normalizeIndexes(strings.Split(rule.rule.Path, "."))
+			if target == path || strings.HasPrefix(target, path+".") || strings.HasPrefix(path, target+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// observePaths flattens data into its leaf paths, sorted, and marks those a
+// consumed path covers. Examples of paths under a sensitive one are redacted.
+func observePaths(data map[string]interface{}, consumed, sensitive []string) []ObservedPath {
+	covered := make(map[string]bool, len(consumed))
+	for _, path := range consumed {
+		covered[normalizeIndexes(strings.Split(path, "."))] = true
+	}
+	hidden := make(map[string]bool, len(sensitive))
+	for _, path := range sensitive {
+		hidden[normalizeIndexes(strings.Split(path, "."))] = true
+	}
+
+	examples := make(map[string]interface{})
+	for key, value := range data {
+		collectLeaves(key, value, examples)
+	}
+
+	paths := make([]ObservedPath, 0, len(examples))
+	for path, example := range examples {
+		if isCovered(path, hidden) && example != nil {
+			example = Redacted
+		}
+		paths = append(paths, ObservedPath{Path: path, Mapped: isCovered(path, covered), Example: example})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].Path < paths[j].Path
+	})
+	return paths
+}
+
+// collectLeaves records the scalars, nulls and empty objects or arrays under
+// path, keeping the first example of each
+func collectLeaves(path string, value interface{}, examples map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for key, elem := range v {
+				collectLeaves(path+"."+key, elem, examples)
+			}
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			for _, elem := range v {
+				collectLeaves(path+".*", elem, examples)
+			}
+			return
+		}
+	}
+
+	if existing, ok := examples[path]; !ok || existing == nil {
+		examples[path] = value
+	}
+}
+
+// isCovered reports whether path or one of its parents is in covered
+func isCovered(path string, covered map[string]bool) bool {
+	for {
+		if covered[path] {
+			return true
+		}
+		i := strings.LastIndexByte(path, '.')
+		if i == -1 {
+			return false
+		}
+		path = path[:i]
+	}
+}
@@ -0,0 +1,199 @@
+package mapper
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// exchangeRatesSection is the output key under which convert_currency records
+// the rate it used for each target field
+const exchangeRatesSection = "_exchange_rates"
+
+type cachedRate struct {
+	rate      *models.ExchangeRate
+	fetchedAt time.Time
+}
+
+func init() {
+	Register("convert_currency", &builtin{
+		signature:   "convert_currency(from_field, to, places?, mode?)",
+		description: "Converts an amount from the currency at from_field to the currency to, rounding to places (default 2). The rate is recorded under _exchange_rates and reused by the inverse, which otherwise divides by the rate in effect (lossy)",
+		minArgs:     2,
+		maxArgs:     4,
+		forward:     convertCurrency,
+		inverse:     convertCurrencyBack,
+		validate: func(args []string) error {
+			if err := validatePath(args[0]); err != nil {
+				return err
+			}
+			if !models.IsValidCurrencyCode(strings.ToUpper(args[1])) {
+				return fmt.Errorf("invalid currency code %q", args[1])
+			}
+			if len(args) > 2 {
+				if n, err := strconv.Atoi(args[2]); err != nil || n < 0 {
+					return fmt.Errorf("decimal places must be a non-negative integer")
+				}
+			}
+			return validateRoundingModeArg(3)(args)
+		},
+		// Rounding, and rates changing between the two directions, keep the
+		// inverse from restoring the exact amount
+		lossy: alwaysLossy,
+	})
+}
+
+func convertCurrency(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	amount, ok := toDecimal(value)
+	if !ok {
+		return value, nil
+	}
+
+	from := strings.ToUpper(strings.TrimSpace(toString(getNestedValue(ctx.Document(), args[0]))))
+	if from == "" {
+		return nil, fmt.Errorf("currency field %q is missing", args[0])
+	}
+	to := strings.ToUpper(args[1])
+
+	rate, err := ctx.ExchangeRate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := toDecimal(rate.Rate)
+	if !ok {
+		return nil, fmt.Errorf("invalid exchange rate %q for %s/%s", rate.Rate, from, to)
+	}
+
+	record := map[string]interface{}{
+		"from": from,
+		"to":   to,
+		"rate": fromDecimal(r),
+	}
+	if !rate.EffectiveAt.IsZero() {
+		record["effective_at"] = rate.EffectiveAt.UTC().Format(time.RFC3339)
+	}
+	ctx.Record(exchangeRatesSection, record)
+
+	converted := amount.Mul(amount, r)
+	return fromDecimal(roundDecimal(converted, currencyPlaces(args), roundingModeArg(args, 3))), nil
+}
+
+// convertCurrencyBack divides by the rate recorded by convertCurrency, so a
+// payload the adapter sent converts back at the same rate. Platform payloads
+// carry no record, so they are divided by the rate in effect now from the
+// MercurJS currency to the platform one.
+func convertCurrencyBack(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	amount, ok := toDecimal(value)
+	if !ok {
+		return value, nil
+	}
+
+	var r *big.Rat
+	if recorded, ok := ctx.Recorded(exchangeRatesSection); ok {
+		record, _ := recorded.(map[string]interface{})
+		r, ok = toDecimal(record["rate"])
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate recorded under %s", exchangeRatesSection)
+		}
+	} else {
+		var err error
+		if r, err = effectiveRateBack(ctx, args); err != nil {
+			return nil, err
+		}
+	}
+
+	converted := amount.Quo(amount, r)
+	return fromDecimal(roundDecimal(converted, currencyPlaces(args), roundingModeArg(args, 3))), nil
+}
+
+// effectiveRateBack returns the rate in effect from the MercurJS currency at
+// args[0] to args[1]. The currency is read from the MercurJS document being
+// rebuilt, or from the platform payload when it was passed through under the
+// same path.
+func effectiveRateBack(ctx *Context, args []string) (*big.Rat, error) {
+	currency := getNestedValue(ctx.result, args[0])
+	if currency == nil {
+		currency = getNestedValue(ctx.Document(), args[0])
+	}
+	from := strings.ToUpper(strings.TrimSpace(toString(currency)))
+	if from == "" {
+		return nil, fmt.Errorf("no exchange rate recorded under %s and currency field %q is missing", exchangeRatesSection, args[0])
+	}
+	to := strings.ToUpper(args[1])
+
+	rate, err := ctx.ExchangeRate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := toDecimal(rate.Rate)
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q for %s/%s", rate.Rate, from, to)
+	}
+	return r, nil
+}
+
+func currencyPlaces(args []string) int {
+	if len(args) > 2 {
+		places, _ := strconv.Atoi(args[2])
+		return places
+	}
+	return 2
+}
+
+// getExchangeRate returns the rate in effect now from one currency to
+// another. When only the opposite pair is stored its reciprocal is used.
+func (m *Mapper) getExchangeRate(from, to string) (*models.ExchangeRate, error) {
+	if from == to {
+		return &models.ExchangeRate{BaseCurrency: from, QuoteCurrency: to, Rate: "1"}, nil
+	}
+
+	cacheKey := from + ":" + to
+
+	m.mu.RLock()
+	generation := m.generation
+	if cached, ok := m.rateCache[cacheKey]; ok && m.fresh(cached.fetchedAt) {
+		m.mu.RUnlock()
+		return cached.rate, nil
+	}
+	m.mu.RUnlock()
+
+	now := time.Now()
+	rate, err := m.rateRepo.FindEffective(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if rate == nil {
+		inverse, err := m.rateRepo.FindEffective(to, from, now)
+		if err != nil {
+			return nil, err
+		}
+		if inverse == nil {
+			return nil, fmt.Errorf("no exchange rate from %s to %s", from, to)
+		}
+
+		r, ok := toDecimal(inverse.Rate)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s/%s", inverse.Rate, to, from)
+		}
+		rate = &models.ExchangeRate{
+			BaseCurrency:  from,
+			QuoteCurrency: to,
+			Rate:          fromDecimal(new(big.Rat).Inv(r)).String(),
+			EffectiveAt:   inverse.EffectiveAt,
+			CreatedAt:     inverse.CreatedAt,
+		}
+	}
+
+	m.mu.Lock()
+	if m.generation == generation {
+		m.rateCache[cacheKey] = cachedRate{rate: rate, fetchedAt: now}
+	}
+	m.mu.Unlock()
+
+	return rate, nil
+}
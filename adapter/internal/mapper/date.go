@@ -0,0 +1,185 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the timezone database: the runtime image has no zoneinfo
+	_ "time/tzdata"
+)
+
+// Named layouts accepted by the date transform. Anything else is used as a Go
+// time layout, e.g. "02/01/2006 15:04".
+const (
+	LayoutUnix    = "unix"     // seconds since the epoch, as a number
+	LayoutUnixMs  = "unix_ms"  // milliseconds since the epoch, as a number
+	LayoutRFC3339 = "rfc3339"  // 2006-01-02T15:04:05Z07:00 with optional fractional seconds
+	LayoutISO     = "iso"      // JavaScript toISOString style: 2006-01-02T15:04:05.000Z07:00
+	LayoutISODate = "iso_date" // 2006-01-02
+)
+
+var namedLayouts = map[string]string{
+	LayoutRFC3339: time.RFC3339Nano,
+	LayoutISO:     "2006-01-02T15:04:05.000Z07:00",
+	LayoutISODate: "2006-01-02",
+}
+
+func init() {
+	Register("date", &builtin{
+		signature:   "date(in, out, tz?)",
+		description: "Converts a date between layouts: unix, unix_ms, rfc3339, iso, iso_date or a Go layout. tz (default UTC) is used for iso_date and Go layouts; rfc3339 and iso are written in UTC",
+		minArgs:     2,
+		maxArgs:     3,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return convertDate(value, args[0], args[1], dateLocation(args))
+		},
+		inverse: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return convertDate(value, args[1], args[0], dateLocation(args))
+		},
+		lossy: func(args []string) bool {
+			return !dateRoundTrips(args[0], args[1], dateLocation(args))
+		},
+		validate: func(args []string) error {
+			for _, layout := range args[:2] {
+				if err := validateDateLayout(layout); err != nil {
+					return err
+				}
+			}
+			if len(args) > 2 {
+				if _, err := time.LoadLocation(args[2]); err != nil {
+					return fmt.Errorf("unknown timezone %q", args[2])
+				}
+			}
+			return nil
+		},
+	})
+
+	Register("date_iso", &builtin{
+		signature:   "date_iso",
+		description: "Converts a 2006-01-02 date to RFC3339, same as date(iso_date, rfc3339)",
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return convertDate(value, LayoutISODate, LayoutRFC3339, time.UTC)
+		},
+		inverse: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return convertDate(value, LayoutRFC3339, LayoutISODate, time.UTC)
+		},
+	})
+}
+
+// convertDate parses value with the in layout and formats it with the out
+// layout. Values that do not parse are returned unchanged, like the other
+// scalar transforms do with values of the wrong type.
+func convertDate(value interface{}, in, out string, loc *time.Location) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	t, ok := parseDate(value, in, loc)
+	if !ok {
+		return value, nil
+	}
+	return formatDate(t, out, loc), nil
+}
+
+func parseDate(value interface{}, layout string, loc *time.Location) (time.Time, bool) {
+	switch layout {
+	case LayoutUnix, LayoutUnixMs:
+		r, ok := toDecimal(value)
+		if !ok {
+			return time.Time{}, false
+		}
+		// Work in whole milliseconds so fractional seconds are kept
+		if layout == LayoutUnix {
+			r.Mul(r, pow10(3))
+		}
+		ms := roundDecimal(r, 0, RoundFloor).Num()
+		if !ms.IsInt64() {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(ms.Int64()).In(loc), true
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	goLayout := layout
+	switch layout {
+	case LayoutRFC3339, LayoutISO:
+		// Fractional seconds are optional when parsing either layout
+		goLayout = time.RFC3339
+	case LayoutISODate:
+		goLayout = namedLayouts[LayoutISODate]
+	}
+
+	t, err := time.ParseInLocation(goLayout, strings.TrimSpace(s), loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func formatDate(t time.Time, layout string, loc *time.Location) interface{} {
+	switch layout {
+	case LayoutUnix:
+		if t.UnixMilli()%1000 == 0 {
+			return json.Number(strconv.FormatInt(t.Unix(), 10))
+		}
+		r, _ := toDecimal(t.UnixMilli())
+		return fromDecimal(r.Quo(r, pow10(3)))
+	case LayoutUnixMs:
+		return json.Number(strconv.FormatInt(t.UnixMilli(), 10))
+	}
+
+	switch layout {
+	case LayoutRFC3339, LayoutISO:
+		// Zoned layouts are always written in UTC, the way MercurJS sends them
+		return t.UTC().Format(namedLayouts[layout])
+	case LayoutISODate:
+		return t.In(loc).Format(namedLayouts[layout])
+	}
+	return t.In(loc).Format(layout)
+}
+
+// dateLocation returns the timezone argument of a date step, UTC by default.
+// Arguments are checked by Validate, so an error here cannot happen.
+func dateLocation(args []string) *time.Location {
+	if len(args) > 2 {
+		if loc, err := time.LoadLocation(args[2]); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// dateRoundTrips reports whether a date written in the in layout survives a
+// conversion to the out layout and back, e.g. rfc3339 -> iso_date loses the
+// time of day
+func dateRoundTrips(in, out string, loc *time.Location) bool {
+	sample := formatDate(time.Date(2011, time.November, 12, 13, 14, 15, 123456789, time.UTC), in, loc)
+	converted, _ := convertDate(sample, in, out, loc)
+	restored, _ := convertDate(converted, out, in, loc)
+	return restored == sample
+}
+
+func validateDateLayout(layout string) error {
+	switch layout {
+	case LayoutUnix, LayoutUnixMs:
+		return nil
+	}
+	if _, ok := namedLayouts[layout]; ok {
+		return nil
+	}
+
+	// A Go layout must contain at least one reference time element, so
+	// formatting any other time changes it
+	sample := time.Date(2011, time.November, 12, 13, 14, 15, 0, time.UTC)
+	if sample.Format(layout) == layout {
+		return fmt.Errorf("unknown date layout %q", layout)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding modes accepted by the money and round transforms
+const (
+	RoundHalfUp   = "half_up"   // ties away from zero
+	RoundHalfEven = "half_even" // ties to the even neighbour (banker's rounding)
+	RoundDown     = "down"      // towards zero
+	RoundUp       = "up"        // away from zero
+	RoundFloor    = "floor"     // towards negative infinity
+	RoundCeiling  = "ceiling"   // towards positive infinity
+)
+
+// defaultRoundingMode is used when a transform is not given a mode
+const defaultRoundingMode = RoundHalfUp
+
+// maxDecimalPlaces bounds the output of non-terminating fractions
+const maxDecimalPlaces = 18
+
+func isValidRoundingMode(mode string) bool {
+	switch mode {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp, RoundFloor, RoundCeiling:
+		return true
+	}
+	return false
+}
+
+// DecodeJSON unmarshals data keeping numbers as json.Number, so amounts and
+// large IDs survive a round trip without float64 precision loss. Payloads
+// passed to the mapper are decoded with it.
+func DecodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// toDecimal converts a numeric value to an exact rational. float64 values use
+// their shortest decimal representation, so 19.99 is read as exactly 19.99.
+func toDecimal(v interface{}) (*big.Rat, bool) {
+	var s string
+	switch val := v.(type) {
+	case json.Number:
+		s = val.String()
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	case int:
+		return new(big.Rat).SetInt64(int64(val)), true
+	case int64:
+		return new(big.Rat).SetInt64(val), true
+	case string:
+		s = strings.TrimSpace(val)
+	default:
+		return nil, false
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, false
+	}
+	return r, true
+}
+
+// fromDecimal formats r as a JSON number with no trailing zeros. Values
+// without a finite decimal expansion are cut off at maxDecimalPlaces.
+func fromDecimal(r *big.Rat) json.Number {
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+
+	// A fraction terminates after max(a, b) places when its denominator is 2^a * 5^b
+	denom := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	for new(big.Int).Rem(denom, big.NewInt(2)).Sign() == 0 {
+		denom.Quo(denom, big.NewInt(2))
+		twos++
+	}
+	for new(big.Int).Rem(denom, big.NewInt(5)).Sign() == 0 {
+		denom.Quo(denom, big.NewInt(5))
+		fives++
+	}
+
+	places := twos
+	if fives > places {
+		places = fives
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 || places > maxDecimalPlaces {
+		places = maxDecimalPlaces
+	}
+
+	s := r.FloatString(places)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return json.Number(s)
+}
+
+// roundDecimal rounds r to the given number of decimal places
+func roundDecimal(r *big.Rat, places int, mode string) *big.Rat {
+	scale := pow10(places)
+	scaled := new(big.Rat).Mul(r, scale)
+
+	// Split into integer part (truncated towards zero) and remainder
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		negative := scaled.Sign() < 0
+		// Compare the fractional part against one half: 2*|rem| vs denom
+		half := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(scaled.Denom())
+
+		awayFromZero := false
+		switch mode {
+		case RoundHalfEven:
+			awayFromZero = half > 0 || (half == 0 && quo.Bit(0) == 1)
+		case RoundDown:
+			awayFromZero = false
+		case RoundUp:
+			awayFromZero = true
+		case RoundFloor:
+			awayFromZero = negative
+		case RoundCeiling:
+			awayFromZero = !negative
+		default:
+			awayFromZero = half >= 0
+		}
+
+		if awayFromZero {
+			if negative {
+				quo.Sub(quo, big.NewInt(1))
+			} else {
+				quo.Add(quo, big.NewInt(1))
+			}
+		}
+	}
+
+	return new(big.Rat).Quo(new(big.Rat).SetInt(quo), scale)
+}
+
+// pow10 returns 10^n as a rational
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
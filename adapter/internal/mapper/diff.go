@@ -0,0 +1,99 @@
+package mapper
+
+import "github.com/mercurjs/adapter/internal/models"
+
+// MappingKey identifies a mapping within a platform/entity across versions
+type MappingKey struct {
+	ShopID      string `json:"shop_id,omitempty"`
+	SourceField string `json:"source_field"`
+	Condition   string `json:"condition,omitempty"`
+}
+
+// MappingChange is a mapping present in both sets with different settings
+type MappingChange struct {
+	Key    MappingKey           `json:"key"`
+	Fields []string             `json:"fields"`
+	Before *models.FieldMapping `json:"before"`
+	After  *models.FieldMapping `json:"after"`
+}
+
+// MappingDiff lists the differences between two mapping sets
+type MappingDiff struct {
+	Added   []*models.FieldMapping `json:"added"`
+	Removed []*models.FieldMapping `json:"removed"`
+	Changed []MappingChange        `json:"changed"`
+}
+
+// Empty reports whether the two sets are equivalent
+func (d *MappingDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffMappings compares two mapping sets of the same platform/entity,
+// matching mappings by shop, source field and condition
+func DiffMappings(from, to []*models.FieldMapping) *MappingDiff {
+	diff := &MappingDiff{
+		Added:   []*models.FieldMapping{},
+		Removed: []*models.FieldMapping{},
+		Changed: []MappingChange{},
+	}
+
+	before := make(map[MappingKey]*models.FieldMapping, len(from))
+	for _, mapping := range from {
+		before[keyOf(mapping)] = mapping
+	}
+
+	seen := make(map[MappingKey]bool, len(to))
+	for _, mapping := range to {
+		key := keyOf(mapping)
+		seen[key] = true
+
+		old, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, mapping)
+			continue
+		}
+		if fields := changedFields(old, mapping); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, MappingChange{Key: key, Fields: fields, Before: old, After: mapping})
+		}
+	}
+
+	for _, mapping := range from {
+		if !seen[keyOf(mapping)] {
+			diff.Removed = append(diff.Removed, mapping)
+		}
+	}
+
+	return diff
+}
+
+func keyOf(mapping *models.FieldMapping) MappingKey {
+	return MappingKey{ShopID: mapping.ShopID, SourceField: mapping.SourceField, Condition: mapping.Condition}
+}
+
+// changedFields names the settings that differ between two versions of a mapping
+func changedFields(a, b *models.FieldMapping) []string {
+	var fields []string
+	if a.TargetField != b.TargetField {
+		fields = append(fields, "target_field")
+	}
+	if a.Transform != b.Transform {
+		fields = append(fields, "transform")
+	}
+	if a.SplitPattern != b.SplitPattern {
+		fields = append(fields, "split_pattern")
+	}
+	if a.DefaultValue != b.DefaultValue {
+		fields = append(fields, "default_value")
+	}
+	if a.Required != b.Required {
+		fields = append(fields, "required")
+	}
+	if a.Unset != b.Unset {
+		fields = append(fields, "unset")
+	}
+	if a.IsActive != b.IsActive {
+		fields = append(fields, "is_active")
+	}
+	return fields
+}
@@ -0,0 +1,35 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is returned by Transform when required fields are missing
+// from the source document
+type ValidationError struct {
+	Missing []string `json:"missing"`
+}
+
+func (e *ValidationError) Error() string {
+	return "missing required fields: " + strings.Join(e.Missing, ", ")
+}
+
+// SchemaError is returned by ValidateOutput when mapped data does not match
+// the schema registered for its platform/entity and direction
+type SchemaError struct {
+	Direction  string            `json:"direction"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		if v.Path == "" {
+			parts[i] = v.Message
+		} else {
+			parts[i] = v.Path + " " + v.Message
+		}
+	}
+	return fmt.Sprintf("output does not match the %s schema: %s", e.Direction, strings.Join(parts, "; "))
+}
@@ -0,0 +1,89 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// EffectiveMappings returns the mappings Transform uses for a platform/entity:
+// the platform's own mappings merged over those inherited from its parent
+// chain, and the shop's mappings merged over those. Each mapping's PlatformID
+// and ShopID tell where it comes from.
+func (m *Mapper) EffectiveMappings(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
+	return m.getMappings(platformID, shopID, entityType)
+}
+
+// resolveMappings loads a platform's mappings and merges them over its
+// parent's. visited holds the platforms already on the chain; the whole chain
+// is returned with the mappings.
+func (m *Mapper) resolveMappings(platformID, entityType string, visited []string) ([]*models.FieldMapping, []string, error) {
+	for _, seen := range visited {
+		if seen == platformID {
+			return nil, nil, fmt.Errorf("mapping inheritance cycle: %s -> %s", strings.Join(visited, " -> "), platformID)
+		}
+	}
+	visited = append(visited, platformID)
+
+	own, err := m.repo.FindPublished(platformID, "", entityType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parent string
+	if m.parentRepo != nil {
+		if parent, err = m.parentRepo.FindParent(platformID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var inherited []*models.FieldMapping
+	if parent != "" {
+		if inherited, visited, err = m.resolveMappings(parent, entityType, visited); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return mergeMappings(inherited, own), visited, nil
+}
+
+// mergeMappings overlays own on inherited. A mapping with the same source
+// field and condition replaces the inherited one in place, or removes it if
+// it is an unset entry; the rest of own is appended. Unset entries never
+// appear in the result.
+func mergeMappings(inherited, own []*models.FieldMapping) []*models.FieldMapping {
+	overrides := make(map[string]*models.FieldMapping, len(own))
+	for _, mapping := range own {
+		overrides[mappingKey(mapping)] = mapping
+	}
+
+	merged := make([]*models.FieldMapping, 0, len(inherited)+len(own))
+	used := make(map[string]bool, len(own))
+	for _, mapping := range inherited {
+		key := mappingKey(mapping)
+		override, ok := overrides[key]
+		if !ok {
+			merged = append(merged, mapping)
+			continue
+		}
+		used[key] = true
+		if !override.Unset {
+			merged = append(merged, override)
+		}
+	}
+
+	for _, mapping := range own {
+		if !used[mappingKey(mapping)] && !mapping.Unset {
+			merged = append(merged, mapping)
+		}
+	}
+
+	return merged
+}
+
+// mappingKey identifies a mapping within a platform/entity, matching the
+// field_mappings unique index
+func mappingKey(mapping *models.FieldMapping) string {
+	return mapping.SourceField + "\x00" + mapping.Condition
+}
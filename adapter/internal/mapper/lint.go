@@ -0,0 +1,355 @@
+package mapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// Lint severities. Errors make a mapping set produce wrong output; warnings
+// point at mappings that only work in some cases or in one direction.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// Lint issue codes
+const (
+	LintTargetCollision  = "target_collision"
+	LintPathConflict     = "path_conflict"
+	LintNotInvertible    = "not_invertible"
+	LintUnknownTransform = "unknown_transform"
+	LintInvalidTransform = "invalid_transform"
+)
+
+// LintIssue is a problem found in a mapping set
+type LintIssue struct {
+	Severity string       `json:"severity"`
+	Code     string       `json:"code"`
+	Message  string       `json:"message"`
+	Mappings []MappingKey `json:"mappings"`
+}
+
+// Involves reports whether the issue concerns the mapping with key
+func (i LintIssue) Involves(key MappingKey) bool {
+	for _, k := range i.Mappings {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks the mapping set of a platform/entity, and of a shop when
+// shopID is set: the published mappings, or proposed drafts merged over the
+// inherited ones as in Preview
+func (m *Mapper) Lint(platformID, shopID, entityType string, proposed []*models.FieldMapping) ([]LintIssue, error) {
+	mappings, err := m.candidateMappings(platformID, shopID, entityType, proposed)
+	if err != nil {
+		return nil, err
+	}
+	return LintMappings(mappings), nil
+}
+
+// LintDrafts checks the drafts of a platform/entity the way they would be
+// published: the platform-wide set, and each shop's set merged over it. An
+// issue found in several of these sets is reported once.
+func (m *Mapper) LintDrafts(platformID, entityType string, drafts []*models.FieldMapping) ([]LintIssue, error) {
+	shops := []string{""}
+	seenShop := map[string]bool{"": true}
+	for _, mapping := range drafts {
+		if !seenShop[mapping.ShopID] {
+			seenShop[mapping.ShopID] = true
+			shops = append(shops, mapping.ShopID)
+		}
+	}
+
+	issues := []LintIssue{}
+	seen := make(map[string]bool)
+	for _, shopID := range shops {
+		found, err := m.Lint(platformID, shopID, entityType, drafts)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range found {
+			key := issue.Severity + "\x00" + issue.Code + "\x00" + issue.Message
+			if !seen[key] {
+				seen[key] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// LintErrors returns the messages of the issues that are errors
+func LintErrors(issues []LintIssue) []string {
+	var messages []string
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return messages
+}
+
+// writtenPath is a path a mapping writes in one direction
+type writtenPath struct {
+	path    string
+	mapping *models.FieldMapping
+}
+
+// LintMappings checks the mapping set of a platform/entity for mappings that
+// write the same target, paths that use a segment both as an array and as an
+// object, lossy transforms that break ReverseTransform, and transforms that
+// are not registered. Unset and inactive mappings are ignored.
+func LintMappings(mappings []*models.FieldMapping) []LintIssue {
+	issues := []LintIssue{}
+	var forward, reverse []writtenPath
+
+	for _, mapping := range mappings {
+		if mapping.Unset || !mapping.IsActive {
+			continue
+		}
+
+		issues = append(issues, lintTransform(mapping)...)
+
+		for _, path := range forwardPaths(mapping) {
+			forward = append(forward, writtenPath{path: path, mapping: mapping})
+		}
+		for _, path := range reversePaths(mapping) {
+			reverse = append(reverse, writtenPath{path: path, mapping: mapping})
+		}
+	}
+
+	issues = append(issues, lintCollisions(forward)...)
+	issues = append(issues, lintPathConflicts(forward, LintError, "")...)
+	issues = append(issues, lintPathConflicts(reverse, LintWarning, " in ReverseTransform")...)
+	return issues
+}
+
+// lintTransform checks that every step of the pipeline is registered and
+// valid, and warns when the mapping cannot be reversed
+func lintTransform(mapping *models.FieldMapping) []LintIssue {
+	key := keyOf(mapping)
+	var issues []LintIssue
+	var lossy []string
+
+	if expr := strings.TrimSpace(mapping.Transform); expr != "" {
+		for _, raw := range splitTopLevel(expr, '|') {
+			s, err := parseStep(strings.TrimSpace(raw))
+			if err != nil {
+				issues = append(issues, LintIssue{
+					Severity: LintError,
+					Code:     LintInvalidTransform,
+					Message:  fmt.Sprintf("%s: %v", mapping.SourceField, err),
+					Mappings: []MappingKey{key},
+				})
+				continue
+			}
+
+			impl, ok := GetTransform(s.name)
+			if !ok {
+				issues = append(issues, LintIssue{
+					Severity: LintError,
+					Code:     LintUnknownTransform,
+					Message:  fmt.Sprintf("%s: unknown transform %q", mapping.SourceField, s.name),
+					Mappings: []MappingKey{key},
+				})
+				continue
+			}
+			if err := impl.Validate(s.args); err != nil {
+				issues = append(issues, LintIssue{
+					Severity: LintError,
+					Code:     LintInvalidTransform,
+					Message:  fmt.Sprintf("%s: %s: %v", mapping.SourceField, s.name, err),
+					Mappings: []MappingKey{key},
+				})
+				continue
+			}
+
+			if inv, ok := impl.(Invertible); ok && !inv.Invertible(s.args) {
+				lossy = append(lossy, s.String())
+			}
+		}
+	}
+
+	if len(lossy) > 0 {
+		issues = append(issues, LintIssue{
+			Severity: LintWarning,
+			Code:     LintNotInvertible,
+			Message:  fmt.Sprintf("%s: %s cannot be reversed exactly, so ReverseTransform will not restore the original value", mapping.SourceField, strings.Join(lossy, ", ")),
+			Mappings: []MappingKey{key},
+		})
+	}
+
+	if mapping.SplitPattern != "" {
+		if splitter, err := parseSplit(mapping.SplitPattern, mapping.TargetField); err == nil && !splitter.invertible() {
+			issues = append(issues, LintIssue{
+				Severity: LintWarning,
+				Code:     LintNotInvertible,
+				Message:  fmt.Sprintf("%s: a regex split cannot be joined back, so ReverseTransform skips it", mapping.SourceField),
+				Mappings: []MappingKey{key},
+			})
+		}
+	}
+
+	return issues
+}
+
+// forwardPaths returns the paths a mapping writes in Transform
+func forwardPaths(mapping *models.FieldMapping) []string {
+	if mapping.SplitPattern == "" {
+		return []string{mapping.TargetField}
+	}
+
+	var paths []string
+	for _, target := range strings.Split(mapping.TargetField, ",") {
+		paths = append(paths, strings.TrimSpace(target))
+	}
+	return paths
+}
+
+// reversePaths returns the paths a mapping writes in ReverseTransform
+func reversePaths(mapping *models.FieldMapping) []string {
+	if isTemplate(mapping.SourceField) {
+		tpl, err := parseTemplate(mapping.SourceField)
+		if err != nil {
+			return nil
+		}
+		return tpl.paths
+	}
+	if mapping.SplitPattern != "" {
+		if splitter, err := parseSplit(mapping.SplitPattern, mapping.TargetField); err != nil || !splitter.invertible() {
+			return nil
+		}
+	}
+	return []string{mapping.SourceField}
+}
+
+// lintCollisions reports mappings that write the same target. Mappings with
+// different conditions may be exclusive, so they only get a warning.
+func lintCollisions(paths []writtenPath) []LintIssue {
+	var issues []LintIssue
+
+	for i, a := range paths {
+		for _, b := range paths[i+1:] {
+			if a.mapping == b.mapping || !pathsOverlap(a.path, b.path) {
+				continue
+			}
+
+			issue := LintIssue{
+				Severity: LintError,
+				Code:     LintTargetCollision,
+				Message:  fmt.Sprintf("%s and %s both write %s", a.mapping.SourceField, b.mapping.SourceField, a.path),
+				Mappings: []MappingKey{keyOf(a.mapping), keyOf(b.mapping)},
+			}
+			if a.mapping.Condition != b.mapping.Condition {
+				issue.Severity = LintWarning
+				issue.Message += " unless their conditions are exclusive"
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// pathsOverlap reports whether two paths can address the same value. A
+// wildcard matches any index.
+func pathsOverlap(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if as[i] == bs[i] {
+			continue
+		}
+		if i > 0 && isIndexSegment(as[i]) && isIndexSegment(bs[i]) && (as[i] == "*" || bs[i] == "*") {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Kinds of node a path segment addresses
+const (
+	nodeValue  = "a value"
+	nodeObject = "an object"
+	nodeArray  = "an array"
+)
+
+// lintPathConflicts reports paths that need the same node to be of different
+// kinds, e.g. `items.0.sku` (array) and `items.sku` (object), or `price`
+// (value) and `price.amount` (object)
+func lintPathConflicts(paths []writtenPath, severity, direction string) []LintIssue {
+	type node struct {
+		kind    string
+		mapping *models.FieldMapping
+	}
+
+	var issues []LintIssue
+	nodes := make(map[string]node)
+	reported := make(map[string]bool)
+
+	for _, wp := range paths {
+		segments := strings.Split(wp.path, ".")
+		for i := range segments {
+			kind := nodeValue
+			if i < len(segments)-1 {
+				kind = nodeObject
+				if isIndexSegment(segments[i+1]) {
+					kind = nodeArray
+				}
+			}
+
+			prefix := normalizeIndexes(segments[:i+1])
+			seen, ok := nodes[prefix]
+			if !ok {
+				nodes[prefix] = node{kind: kind, mapping: wp.mapping}
+				continue
+			}
+			// Two values at the same path are a collision, reported separately
+			if seen.kind == kind || reported[prefix] {
+				continue
+			}
+
+			reported[prefix] = true
+			issues = append(issues, LintIssue{
+				Severity: severity,
+				Code:     LintPathConflict,
+				Message:  fmt.Sprintf("%s is used as %s by %s and as %s by %s%s", prefix, seen.kind, seen.mapping.SourceField, kind, wp.mapping.SourceField, direction),
+				Mappings: []MappingKey{keyOf(seen.mapping), keyOf(wp.mapping)},
+			})
+		}
+	}
+
+	return issues
+}
+
+// isIndexSegment reports whether a path segment addresses an array element
+func isIndexSegment(segment string) bool {
+	if segment == "*" {
+		return true
+	}
+	_, err := strconv.Atoi(segment)
+	return err == nil
+}
+
+// normalizeIndexes joins path segments with array indexes replaced by `*`,
+// so every element of an array shares one node. The first segment is always
+// an object key.
+func normalizeIndexes(segments []string) string {
+	normalized := make([]string, len(segments))
+	for i, segment := range segments {
+		if i > 0 && isIndexSegment(segment) {
+			segment = "*"
+		}
+		normalized[i] = segment
+	}
+	return strings.Join(normalized, ".")
+}
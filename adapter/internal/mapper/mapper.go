@@ -2,6 +2,7 @@ package mapper
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,93 +13,360 @@ import (
 )
 
 type Mapper struct {
-	repo  *repository.FieldMappingRepository
-	cache map[string][]*models.FieldMapping
-	mu    sync.RWMutex
-	ttl   time.Duration
+	repo        *repository.FieldMappingRepository
+	lookupRepo  *repository.LookupTableRepository
+	policyRepo  *repository.MappingPolicyRepository
+	rateRepo    *repository.ExchangeRateRepository
+	parentRepo  *repository.MappingParentRepository
+	schemaRepo  *repository.MappingSchemaRepository
+	piiRepo     *repository.PIIPolicyRepository
+	cache       map[string]*cachedMappings
+	lookupCache map[string]*cachedLookup
+	policyCache map[string]*cachedPolicy
+	schemaCache map[string]*cachedSchema
+	piiCache    map[string]*cachedPIIPolicy
+	rateCache   map[string]cachedRate
+	mu          sync.RWMutex
+	ttl         time.Duration
+	// generation counts invalidations, so a load that overlaps one is not cached
+	generation uint64
+	// instanceID tells this instance's broadcasts apart from the others'
+	instanceID     string
+	broadcaster    Broadcaster
+	broadcastTopic string
+	coverage       CoverageObserver
+	coverageRate   float64
 }
 
-func New(repo *repository.FieldMappingRepository) *Mapper {
+func New(repo *repository.FieldMappingRepository, lookupRepo *repository.LookupTableRepository, policyRepo *repository.MappingPolicyRepository, rateRepo *repository.ExchangeRateRepository, parentRepo *repository.MappingParentRepository, schemaRepo *repository.MappingSchemaRepository, piiRepo *repository.PIIPolicyRepository) *Mapper {
 	return &Mapper{
-		repo:  repo,
-		cache: make(map[string][]*models.FieldMapping),
-		ttl:   5 * time.Minute,
+		repo:        repo,
+		lookupRepo:  lookupRepo,
+		policyRepo:  policyRepo,
+		rateRepo:    rateRepo,
+		parentRepo:  parentRepo,
+		schemaRepo:  schemaRepo,
+		piiRepo:     piiRepo,
+		cache:       make(map[string]*cachedMappings),
+		lookupCache: make(map[string]*cachedLookup),
+		policyCache: make(map[string]*cachedPolicy),
+		schemaCache: make(map[string]*cachedSchema),
+		piiCache:    make(map[string]*cachedPIIPolicy),
+		rateCache:   make(map[string]cachedRate),
+		ttl:         5 * time.Minute,
+		instanceID:  newInstanceID(),
 	}
 }
 
-// Transform converts MercurJS JSON to platform-specific format
-func (m *Mapper) Transform(platformID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
-	mappings, err := m.getMappings(platformID, entityType)
+// Transform converts MercurJS JSON to platform-specific format.
+// Mappings scoped to shopID take precedence over the platform-wide ones; pass
+// "" to use only the platform-wide mappings.
+// Missing source fields are filled from the mapping's default value; if a
+// required field has no value and no default a *ValidationError is returned.
+// The platform's PII policy is applied to the output.
+func (m *Mapper) Transform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	return m.transform(newScope(platformID, shopID, entityType), data, false)
+}
+
+// ReverseTransform converts platform-specific format back to MercurJS JSON
+// Uses the same mappings but swaps source/target direction and inverts transforms.
+// The platform/entity unmapped field policy applies in this direction too.
+func (m *Mapper) ReverseTransform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	return m.transform(newScope(platformID, shopID, entityType), data, true)
+}
+
+func (m *Mapper) transform(scope mappingScope, data map[string]interface{}, reverse bool) (map[string]interface{}, error) {
+	entry, err := m.getCachedMappings(scope.platformID, scope.shopID, scope.entityType())
 	if err != nil {
 		return nil, err
 	}
 
-	// If no mappings, return original data
-	if len(mappings) == 0 {
+	policy, err := m.getPolicy(scope.platformID, scope.entityType())
+	if err != nil {
+		return nil, err
+	}
+
+	// If no mappings and no policy, return original data
+	if len(entry.mappings) == 0 && policy == nil {
+		if !reverse {
+			m.observeCoverage(scope, nil, data, nil)
+		}
+		return m.protect(scope, data, reverse)
+	}
+
+	result, consumed, err := m.apply(scope, entry.plan, policy, data, reverse, nil)
+	if !reverse {
+		m.observeCoverage(scope, entry.plan, data, consumed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.protect(scope, result, reverse)
+}
+
+// protect applies the platform's PII policy to the output of Transform. Nested
+// entities are left to the outermost one, whose paths include theirs.
+func (m *Mapper) protect(scope mappingScope, data map[string]interface{}, reverse bool) (map[string]interface{}, error) {
+	if reverse || len(scope.entities) > 1 {
 		return data, nil
 	}
+	return m.applyPIIPolicy(scope.platformID, data)
+}
 
+// apply runs a compiled mapping set over data and applies the unmapped field
+// policy. It returns the result and the input paths the mappings read. With a
+// trace, each mapping is recorded and mapping errors are recorded instead of
+// stopping the run. The result is returned even when required fields are
+// missing.
+func (m *Mapper) apply(scope mappingScope, plan *mappingPlan, policy *models.MappingPolicy, data map[string]interface{}, reverse bool, trace *[]*MappingTrace) (map[string]interface{}, []string, error) {
 	result := make(map[string]interface{})
+	var consumed, missing []string
 
-	for _, mapping := range mappings {
-		value := getNestedValue(data, mapping.SourceField)
-		if value == nil {
+	// Conditions are written against MercurJS fields, so in reverse they are
+	// evaluated against the MercurJS document being rebuilt
+	conditionDoc := data
+	if reverse && plan.conditional {
+		conditionDoc = m.reverseConditionDocument(scope, plan, policy, data)
+	}
+
+	for _, c := range plan.mappings {
+		var t *MappingTrace
+		if trace != nil {
+			t = newMappingTrace(c.mapping)
+			*trace = append(*trace, t)
+		}
+
+		reads, status, err := m.applyMapping(scope, c, data, conditionDoc, result, reverse, t)
+		if err != nil {
+			err = mappingError(c.mapping, reverse, err)
+			if t == nil {
+				return nil, consumed, err
+			}
+			t.Status = StatusError
+			t.Error = err.Error()
 			continue
 		}
+		if t != nil {
+			t.Status = status
+		}
 
-		// Apply transform if specified
-		if mapping.Transform != "" {
-			value = applyTransform(value, mapping.Transform)
+		consumed = append(consumed, reads...)
+		if status == StatusMissingRequired {
+			missing = append(missing, c.mapping.SourceField)
 		}
+	}
 
-		// Set nested value in result
-		setNestedValue(result, mapping.TargetField, value)
+	result = applyUnmappedPolicy(policy, data, result, consumed)
+	if len(missing) > 0 {
+		return result, consumed, &ValidationError{Missing: missing}
 	}
+	return result, consumed, nil
+}
 
-	return result, nil
+// reverseConditionDocument returns the MercurJS document ReverseTransform
+// would produce from data without the conditional mappings, for their
+// conditions to be evaluated against. Mapping errors are left to the actual
+// run to report.
+func (m *Mapper) reverseConditionDocument(scope mappingScope, plan *mappingPlan, policy *models.MappingPolicy, data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	var consumed []string
+
+	for _, c := range plan.mappings {
+		if c.condition != nil || c.conditionErr != nil {
+			continue
+		}
+		reads, _, err := m.applyMapping(scope, c, data, nil, result, true, nil)
+		if err != nil {
+			continue
+		}
+		consumed = append(consumed, reads...)
+	}
+
+	return applyUnmappedPolicy(policy, data, result, consumed)
 }
 
-// ReverseTransform converts platform-specific format back to MercurJS JSON
-// Uses the same mappings but swaps source/target direction and inverts transforms
-func (m *Mapper) ReverseTransform(platformID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
-	mappings, err := m.getMappings(platformID, entityType)
+// applyMapping applies one mapping. It returns the input paths it consumed
+// and a Status* value describing the outcome. The mapping's condition is
+// evaluated against conditionDoc, which is always a MercurJS document.
+func (m *Mapper) applyMapping(scope mappingScope, c *compiledMapping, data, conditionDoc, result map[string]interface{}, reverse bool, t *MappingTrace) ([]string, string, error) {
+	// Skip mappings whose condition does not hold for this document
+	if c.conditionErr != nil {
+		return nil, "", c.conditionErr
+	}
+	if c.condition != nil && !truthy(c.condition.eval(conditionDoc)) {
+		return nil, StatusSkipped, nil
+	}
+	if c.err != nil {
+		return nil, "", c.err
+	}
+
+	ctx := &Context{mapper: m, scope: scope, doc: data, result: result, field: c.mapping.TargetField, reverse: reverse, trace: t}
+
+	var reads []string
+	var written int
+	var err error
+	if reverse {
+		reads, written, err = applyReverse(ctx, c)
+	} else {
+		reads, written, err = applyForward(ctx, c)
+	}
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	reads = append(reads, ctx.reads...)
 
-	if len(mappings) == 0 {
-		return data, nil
+	if written > 0 {
+		return reads, StatusMapped, nil
+	}
+	if reverse {
+		return reads, StatusMissing, nil
 	}
 
-	result := make(map[string]interface{})
+	// Source is missing: fall back to the default value, or report it if required
+	if c.hasDefault {
+		if c.defaultErr != nil {
+			return nil, "", c.defaultErr
+		}
+		// The plan is shared between calls, so objects and arrays are copied
+		value := deepCopy(c.defaultValue)
+		c.target.set(result, nil, value)
+		if t != nil {
+			t.Values = append(t.Values, ValueTrace{Written: value})
+		}
+		return reads, StatusDefault, nil
+	}
+	if c.mapping.Required {
+		return reads, StatusMissingRequired, nil
+	}
+	return reads, StatusMissing, nil
+}
 
-	for _, mapping := range mappings {
-		// Read from TargetField (platform field) instead of SourceField
-		value := getNestedValue(data, mapping.TargetField)
+// applyForward writes one mapping's value(s) from a MercurJS document into
+// result. It returns the source paths it read and how many values it wrote.
+func applyForward(ctx *Context, c *compiledMapping) ([]string, int, error) {
+	data, result := ctx.doc, ctx.result
+	forward := func(value interface{}) (interface{}, error) {
+		return applySteps(ctx, value, c.steps)
+	}
+
+	switch {
+	case c.splitter != nil:
+		reads := []string{c.mapping.SourceField}
+		value := c.source.get(data)
 		if value == nil {
-			continue
+			return reads, 0, nil
 		}
 
-		// Apply inverse transform if specified
-		if mapping.Transform != "" {
-			value = applyInverseTransform(value, mapping.Transform)
+		written := 0
+		for i, part := range c.splitter.split(toString(value)) {
+			transformed, err := forward(part)
+			if err != nil {
+				return nil, 0, err
+			}
+			c.splitter.fields[i].set(result, nil, transformed)
+			written++
 		}
+		return reads, written, nil
 
-		// Write to SourceField (MercurJS field) instead of TargetField
-		setNestedValue(result, mapping.SourceField, value)
+	case c.template != nil:
+		rendered, ok := c.template.render(data)
+		if !ok {
+			return c.template.paths, 0, nil
+		}
+
+		transformed, err := forward(rendered)
+		if err != nil {
+			return nil, 0, err
+		}
+		c.target.set(result, nil, transformed)
+		return c.template.paths, 1, nil
 	}
 
-	return result, nil
+	written, err := copyField(data, result, c.source, c.target, func(path string, value interface{}) (interface{}, error) {
+		ctx.path = path
+		return forward(value)
+	})
+	return []string{c.mapping.SourceField}, written, err
+}
+
+// applyReverse writes one mapping's value(s) from a platform document back
+// into result. It returns the platform paths it read and how many values it
+// wrote.
+func applyReverse(ctx *Context, c *compiledMapping) ([]string, int, error) {
+	data, result := ctx.doc, ctx.result
+	inverse := func(value interface{}) (interface{}, error) {
+		return applyInverseSteps(ctx, value, c.steps)
+	}
+
+	switch {
+	case c.splitter != nil:
+		// Regex splits drop the text between groups and cannot be joined back
+		if !c.splitter.invertible() {
+			return nil, 0, nil
+		}
+
+		parts := make([]string, 0, len(c.splitter.fields))
+		for _, field := range c.splitter.fields {
+			value := field.get(data)
+			if value == nil {
+				break
+			}
+			restored, err := inverse(value)
+			if err != nil {
+				return nil, 0, err
+			}
+			parts = append(parts, toString(restored))
+		}
+		if len(parts) == 0 {
+			return c.splitter.targets, 0, nil
+		}
+
+		c.source.set(result, nil, c.splitter.join(parts))
+		return c.splitter.targets, 1, nil
+
+	case c.template != nil:
+		reads := []string{c.mapping.TargetField}
+		value := c.target.get(data)
+		if value == nil {
+			return reads, 0, nil
+		}
+
+		restored, err := inverse(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		values, ok := c.template.parse(toString(restored))
+		if !ok {
+			return reads, 0, nil
+		}
+		for i, field := range c.template.fields {
+			field.set(result, nil, values[c.template.paths[i]])
+		}
+		return reads, len(values), nil
+	}
+
+	written, err := copyField(data, result, c.target, c.source, func(path string, value interface{}) (interface{}, error) {
+		ctx.path = path
+		return inverse(value)
+	})
+	return []string{c.mapping.TargetField}, written, err
+}
+
+func mappingError(mapping *models.FieldMapping, reverse bool, err error) error {
+	if reverse {
+		return fmt.Errorf("mapping %s -> %s: %w", mapping.TargetField, mapping.SourceField, err)
+	}
+	return fmt.Errorf("mapping %s -> %s: %w", mapping.SourceField, mapping.TargetField, err)
 }
 
 // TransformJSON transforms JSON bytes
-func (m *Mapper) TransformJSON(platformID, entityType string, jsonData []byte) ([]byte, error) {
+func (m *Mapper) TransformJSON(platformID, shopID, entityType string, jsonData []byte) ([]byte, error) {
 	var data map[string]interface{}
-	if err := json.Unmarshal(jsonData, &data); err != nil {
+	if err := DecodeJSON(jsonData, &data); err != nil {
 		return nil, err
 	}
 
-	result, err := m.Transform(platformID, entityType, data)
+	result, err := m.Transform(platformID, shopID, entityType, data)
 	if err != nil {
 		return nil, err
 	}
@@ -106,167 +374,206 @@ func (m *Mapper) TransformJSON(platformID, entityType string, jsonData []byte) (
 	return json.Marshal(result)
 }
 
-func (m *Mapper) getMappings(platformID, entityType string) ([]*models.FieldMapping, error) {
-	cacheKey := platformID + ":" + entityType
+// getMappings returns the effective mappings for a platform/entity, with
+// inherited mappings resolved and, for a shop, its own mappings merged over
+// the platform's
+func (m *Mapper) getMappings(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
+	entry, err := m.getCachedMappings(platformID, shopID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return entry.mappings, nil
+}
+
+func (m *Mapper) getCachedMappings(platformID, shopID, entityType string) (*cachedMappings, error) {
+	cacheKey := platformID + ":" + shopID + ":" + entityType
 
 	m.mu.RLock()
-	if cached, ok := m.cache[cacheKey]; ok {
+	generation := m.generation
+	if cached, ok := m.cache[cacheKey]; ok && m.fresh(cached.fetchedAt) {
 		m.mu.RUnlock()
 		return cached, nil
 	}
 	m.mu.RUnlock()
 
-	mappings, err := m.repo.FindByPlatformAndEntity(platformID, entityType)
-	if err != nil {
-		return nil, err
+	entry := &cachedMappings{entityType: entityType, fetchedAt: time.Now()}
+	if shopID == "" {
+		mappings, platforms, err := m.resolveMappings(platformID, entityType, nil)
+		if err != nil {
+			return nil, err
+		}
+		entry.mappings, entry.platforms = mappings, platforms
+	} else {
+		platformEntry, err := m.getCachedMappings(platformID, "", entityType)
+		if err != nil {
+			return nil, err
+		}
+		shopMappings, err := m.repo.FindPublished(platformID, shopID, entityType)
+		if err != nil {
+			return nil, err
+		}
+		entry.mappings = mergeMappings(platformEntry.mappings, shopMappings)
+		entry.platforms = platformEntry.platforms
 	}
 
+	entry.plan = compilePlan(entry.mappings)
+
 	m.mu.Lock()
-	m.cache[cacheKey] = mappings
+	if m.generation == generation {
+		m.cache[cacheKey] = entry
+	}
 	m.mu.Unlock()
 
-	return mappings, nil
+	return entry, nil
 }
 
-// ClearCache clears the mapping cache
-func (m *Mapper) ClearCache() {
-	m.mu.Lock()
-	m.cache = make(map[string][]*models.FieldMapping)
-	m.mu.Unlock()
-}
+func (m *Mapper) getLookupTable(name string) (*models.LookupTable, error) {
+	m.mu.RLock()
+	generation := m.generation
+	if cached, ok := m.lookupCache[name]; ok && m.fresh(cached.fetchedAt) {
+		m.mu.RUnlock()
+		return cached.table, nil
+	}
+	m.mu.RUnlock()
 
-// getNestedValue gets value from nested map using dot notation
-// e.g., "variants.0.price" -> data["variants"][0]["price"]
-func getNestedValue(data map[string]interface{}, path string) interface{} {
-	parts := strings.Split(path, ".")
-	var current interface{} = data
-
-	for _, part := range parts {
-		switch v := current.(type) {
-		case map[string]interface{}:
-			current = v[part]
-		case []interface{}:
-			idx, err := strconv.Atoi(part)
-			if err != nil || idx < 0 || idx >= len(v) {
-				return nil
-			}
-			current = v[idx]
-		default:
-			return nil
-		}
+	fetchedAt := time.Now()
+	table, err := m.lookupRepo.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return nil, fmt.Errorf("lookup table %q not found", name)
+	}
 
-		if current == nil {
-			return nil
-		}
+	m.mu.Lock()
+	if m.generation == generation {
+		m.lookupCache[name] = &cachedLookup{table: table, fetchedAt: fetchedAt}
 	}
+	m.mu.Unlock()
 
-	return current
+	return table, nil
 }
 
-// setNestedValue sets value in nested map using dot notation
-func setNestedValue(data map[string]interface{}, path string, value interface{}) {
-	parts := strings.Split(path, ".")
-	updated := setNestedNode(data, parts, value)
-	if obj, ok := updated.(map[string]interface{}); ok {
-		for k := range data {
-			delete(data, k)
-		}
-		for k, v := range obj {
-			data[k] = v
-		}
-	}
-}
+// getPolicy returns the unmapped field policy for a platform/entity, or nil
+// if none is configured
+func (m *Mapper) getPolicy(platformID, entityType string) (*models.MappingPolicy, error) {
+	cacheKey := platformID + ":" + entityType
 
-func setNestedNode(current interface{}, parts []string, value interface{}) interface{} {
-	if len(parts) == 0 {
-		return value
+	m.mu.RLock()
+	generation := m.generation
+	if cached, ok := m.policyCache[cacheKey]; ok && m.fresh(cached.fetchedAt) {
+		m.mu.RUnlock()
+		return cached.policy, nil
 	}
+	m.mu.RUnlock()
 
-	part := parts[0]
-	if idx, err := strconv.Atoi(part); err == nil {
-		var arr []interface{}
-		if existing, ok := current.([]interface{}); ok {
-			arr = existing
-		}
-
-		for len(arr) <= idx {
-			arr = append(arr, nil)
-		}
-		arr[idx] = setNestedNode(arr[idx], parts[1:], value)
-		return arr
+	fetchedAt := time.Now()
+	policy, err := m.policyRepo.Find(platformID, entityType)
+	if err != nil {
+		return nil, err
 	}
 
-	var obj map[string]interface{}
-	if existing, ok := current.(map[string]interface{}); ok && existing != nil {
-		obj = existing
-	} else {
-		obj = make(map[string]interface{})
+	m.mu.Lock()
+	if m.generation == generation {
+		m.policyCache[cacheKey] = &cachedPolicy{policy: policy, fetchedAt: fetchedAt}
 	}
+	m.mu.Unlock()
 
-	obj[part] = setNestedNode(obj[part], parts[1:], value)
-	return obj
+	return policy, nil
 }
 
-// applyTransform applies a transform function to a value
-func applyTransform(value interface{}, transform string) interface{} {
-	switch transform {
-	case "uppercase":
-		if s, ok := value.(string); ok {
-			return strings.ToUpper(s)
+// copyField copies the value(s) at path `from` in src to path `to` in dst,
+// passing them through fn. Wildcard segments (`*`) walk every array element
+// and keep the element correspondence:
+//   - "variants.*.price" -> "items.*.cost" copies element by element
+//   - "variants.*.price" -> "prices" collects the values into one array
+//   - "prices" -> "variants.*.price" spreads an array over the elements
+//
+// Collected values go through fn as one array, and an array is spread after
+// fn, so list transforms such as sum or split see the whole array. Values fn
+// turns into nil are not written. fn also gets the path the value was read
+// from, or "" for collected values.
+func copyField(src, dst map[string]interface{}, from, to fieldPath, fn func(string, interface{}) (interface{}, error)) (int, error) {
+	written := 0
+
+	switch {
+	case from.wildcards == to.wildcards:
+		err := from.each(src, func(indexes []int, value interface{}) error {
+			value, err := fn(from.resolve(indexes), value)
+			if err != nil || value == nil {
+				return err
+			}
+			to.set(dst, indexes, value)
+			written++
+			return nil
+		})
+		return written, err
+	case to.wildcards == 0:
+		var values []interface{}
+		_ = from.each(src, func(_ []int, value interface{}) error {
+			values = append(values, value)
+			return nil
+		})
+		if len(values) == 0 {
+			return 0, nil
+		}
+		value, err := fn("", values)
+		if err != nil || value == nil {
+			return 0, err
 		}
-	case "lowercase":
-		if s, ok := value.(string); ok {
-			return strings.ToLower(s)
+		to.set(dst, nil, value)
+		written++
+	case from.wildcards == 0 && to.wildcards == 1:
+		value := from.get(src)
+		if value == nil {
+			return 0, nil
 		}
-	case "cents_to_dollars":
-		switch v := value.(type) {
-		case float64:
-			return v / 100
-		case int:
-			return float64(v) / 100
+		value, err := fn(from.raw, value)
+		if err != nil {
+			return 0, err
 		}
-	case "dollars_to_cents":
-		switch v := value.(type) {
-		case float64:
-			return int(v * 100)
-		case int:
-			return v * 100
+		arr, ok := value.([]interface{})
+		if !ok {
+			return 0, nil
 		}
-	case "string":
-		return toString(value)
-	case "int":
-		return toInt(value)
-	case "bool":
-		return toBool(value)
-	case "date_iso":
-		if s, ok := value.(string); ok {
-			t, err := time.Parse("2006-01-02", s)
-			if err == nil {
-				return t.Format(time.RFC3339)
+		index := make([]int, 1)
+		for i, elem := range arr {
+			if elem == nil {
+				continue
 			}
+			index[0] = i
+			to.set(dst, index, elem)
+			written++
 		}
+	default:
+		return 0, fmt.Errorf("wildcard count mismatch between %q and %q", from, to)
 	}
-	return value
+
+	return written, nil
 }
 
-// applyInverseTransform applies the inverse of a transform function
-func applyInverseTransform(value interface{}, transform string) interface{} {
-	switch transform {
-	case "cents_to_dollars":
-		return applyTransform(value, "dollars_to_cents")
-	case "dollars_to_cents":
-		return applyTransform(value, "cents_to_dollars")
-	default:
-		// Most transforms (uppercase, lowercase, string, int, bool, date_iso) are
-		// applied the same way in both directions
-		return applyTransform(value, transform)
+func countWildcards(path string) int {
+	count := 0
+	for _, part := range strings.Split(path, ".") {
+		if part == "*" {
+			count++
+		}
 	}
+	return count
+}
+
+// getNestedValue gets value from nested map using dot notation
+// e.g., "variants.0.price" -> data["variants"][0]["price"]
+func getNestedValue(data map[string]interface{}, path string) interface{} {
+	return compilePath(path).get(data)
 }
 
 func toString(v interface{}) string {
 	switch val := v.(type) {
 	case string:
 		return val
+	case json.Number:
+		return val.String()
 	case float64:
 		return strconv.FormatFloat(val, 'f', -1, 64)
 	case int:
@@ -280,6 +587,12 @@ func toString(v interface{}) string {
 
 func toInt(v interface{}) int {
 	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := val.Float64()
+		return int(f)
 	case float64:
 		return int(val)
 	case int:
@@ -298,6 +611,9 @@ func toBool(v interface{}) bool {
 		return val
 	case string:
 		return val == "true" || val == "1" || val == "yes"
+	case json.Number:
+		f, _ := val.Float64()
+		return f != 0
 	case float64:
 		return val != 0
 	case int:
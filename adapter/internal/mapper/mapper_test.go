@@ -0,0 +1,2093 @@
+package mapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// newTestMapper returns a Mapper whose cache is primed with mappings for
+// platform "test" (no shop) and the given entity, so no database is needed
+func newTestMapper(entityType string, mappings ...*models.FieldMapping) *Mapper {
+	m := New(nil, nil, nil, nil, nil, nil, nil)
+	primeTestMappings(m, entityType, mappings...)
+	return m
+}
+
+// primeTestMappings caches mappings for another entity of platform "test"
+func primeTestMappings(m *Mapper, entityType string, mappings ...*models.FieldMapping) {
+	for _, mapping := range mappings {
+		mapping.IsActive = true
+	}
+	m.cache["test::"+entityType] = &cachedMappings{mappings: mappings, plan: compilePlan(mappings), entityType: entityType, platforms: []string{"test"}, fetchedAt: time.Now()}
+	m.policyCache["test:"+entityType] = &cachedPolicy{fetchedAt: time.Now()}
+}
+
+func TestDollarsToCentsIsExact(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{19.99, "1999"},
+		{json.Number("19.99"), "1999"},
+		{0.29, "29"},
+		{1.005, "101"},
+		{"4.35", "435"},
+		{-19.99, "-1999"},
+		{100, "10000"},
+	}
+
+	for _, tt := range tests {
+		got := dollarsToCents(tt.value, nil)
+		if got != json.Number(tt.want) {
+			t.Errorf("dollarsToCents(%v) = %v, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCentsToDollarsIsExact(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{json.Number("1999"), "19.99"},
+		{1999.0, "19.99"},
+		{1900, "19"},
+		{json.Number("1"), "0.01"},
+		{json.Number("-5"), "-0.05"},
+	}
+
+	for _, tt := range tests {
+		got := centsToDollars(tt.value, nil)
+		if got != json.Number(tt.want) {
+			t.Errorf("centsToDollars(%v) = %v, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		value string
+		mode  string
+		want  string
+	}{
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.3451", RoundHalfEven, "2.35"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+		{"2.341", RoundUp, "2.35"},
+		{"-2.341", RoundUp, "-2.35"},
+		{"-2.341", RoundFloor, "-2.35"},
+		{"2.349", RoundFloor, "2.34"},
+		{"-2.349", RoundCeiling, "-2.34"},
+		{"2.341", RoundCeiling, "2.35"},
+		{"2.3", RoundHalfUp, "2.3"},
+	}
+
+	for _, tt := range tests {
+		r, _ := toDecimal(json.Number(tt.value))
+		got := fromDecimal(roundDecimal(r, 2, tt.mode))
+		if got != json.Number(tt.want) {
+			t.Errorf("round(%s, %s) = %s, want %s", tt.value, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineRejectsUnknownRoundingMode(t *testing.T) {
+	if err := ValidatePipeline("dollars_to_cents(nearest)"); err == nil {
+		t.Error("expected an error for an unknown rounding mode")
+	}
+	if err := ValidatePipeline("round(2, half_even)"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMoneyRoundTrip(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "amount", TargetField: "price", Transform: "cents_to_dollars"},
+		&models.FieldMapping{SourceField: "variants.*.amount", TargetField: "items.*.price_cents", Transform: "cents_to_dollars|dollars_to_cents"},
+		&models.FieldMapping{SourceField: "id", TargetField: "external_id"},
+	)
+
+	for _, amount := range []string{"0", "1", "29", "1999", "1998", "100000001", "-4299", "9007199254740993"} {
+		payload := []byte(`{"amount": ` + amount + `, "variants": [{"amount": ` + amount + `}], "id": 1234567890123456789}`)
+
+		out, err := m.TransformJSON("test", "", "product", payload)
+		if err != nil {
+			t.Fatalf("TransformJSON(%s): %v", amount, err)
+		}
+
+		var platform map[string]interface{}
+		if err := DecodeJSON(out, &platform); err != nil {
+			t.Fatalf("decode %s: %v", out, err)
+		}
+		if got := platform["external_id"]; got != json.Number("1234567890123456789") {
+			t.Errorf("external_id = %v, want 1234567890123456789", got)
+		}
+
+		back, err := m.ReverseTransform("test", "", "product", platform)
+		if err != nil {
+			t.Fatalf("ReverseTransform(%s): %v", amount, err)
+		}
+		if got := back["amount"]; got != json.Number(amount) {
+			t.Errorf("amount round trip: got %v, want %s (platform price %v)", got, amount, platform["price"])
+		}
+		if got := getNestedValue(back, "variants.0.amount"); got != json.Number(amount) {
+			t.Errorf("variant amount round trip: got %v, want %s", got, amount)
+		}
+	}
+}
+
+func TestDollarPricesRoundTripToTheCent(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "total", TargetField: "total_cents", Transform: "dollars_to_cents"},
+	)
+
+	// Every price from 0.00 to 99.99 must survive float64 input as well
+	for cents := 0; cents < 10000; cents++ {
+		dollars := float64(cents) / 100
+		out, err := m.Transform("test", "", "order", map[string]interface{}{"total": dollars})
+		if err != nil {
+			t.Fatalf("Transform(%v): %v", dollars, err)
+		}
+		if got := toInt(out["total_cents"]); got != cents {
+			t.Fatalf("Transform(%v) = %v cents, want %d", dollars, out["total_cents"], cents)
+		}
+
+		back, err := m.ReverseTransform("test", "", "order", out)
+		if err != nil {
+			t.Fatalf("ReverseTransform(%v): %v", out, err)
+		}
+		want, _ := toDecimal(dollars)
+		got, ok := toDecimal(back["total"])
+		if !ok || got.Cmp(want) != 0 {
+			t.Fatalf("round trip of %v = %v", dollars, back["total"])
+		}
+	}
+}
+
+func TestDateRoundTrip(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "created_at", TargetField: "create_time", Transform: "date(iso, unix)"},
+		&models.FieldMapping{SourceField: "updated_at", TargetField: "update_time", Transform: "date(rfc3339, unix_ms)"},
+		&models.FieldMapping{SourceField: "ship_by", TargetField: "ship_by_date", Transform: `date(iso_date, "02/01/2006", Asia/Bangkok)`},
+		&models.FieldMapping{SourceField: "paid_at", TargetField: "paid_local", Transform: `date(iso, "2006-01-02 15:04:05", Asia/Bangkok)`},
+		&models.FieldMapping{SourceField: "delivered_on", TargetField: "delivered_at", Transform: "date_iso"},
+	)
+
+	mercur := map[string]interface{}{
+		"created_at":   "2024-03-01T10:00:00.000Z",
+		"updated_at":   "2024-03-01T10:00:00.123Z",
+		"ship_by":      "2024-03-05",
+		"paid_at":      "2024-03-01T20:30:00.000Z",
+		"delivered_on": "2024-03-07",
+	}
+
+	platform, err := m.Transform("test", "", "order", mercur)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"create_time":  json.Number("1709287200"),
+		"update_time":  json.Number("1709287200123"),
+		"ship_by_date": "05/03/2024",
+		"paid_local":   "2024-03-02 03:30:00",
+		"delivered_at": "2024-03-07T00:00:00Z",
+	}
+	for field, value := range want {
+		if platform[field] != value {
+			t.Errorf("%s = %#v, want %#v", field, platform[field], value)
+		}
+	}
+
+	back, err := m.ReverseTransform("test", "", "order", platform)
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	for field, value := range mercur {
+		if back[field] != value {
+			t.Errorf("round trip of %s = %#v, want %#v", field, back[field], value)
+		}
+	}
+}
+
+func TestDateFromShopeeTimestamp(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "created_at", TargetField: "create_time", Transform: "date(iso, unix)"},
+	)
+
+	// Shopee sends epoch seconds, usually decoded as a number
+	for _, ts := range []interface{}{json.Number("1709287200"), 1709287200.0, "1709287200"} {
+		back, err := m.ReverseTransform("test", "", "order", map[string]interface{}{"create_time": ts})
+		if err != nil {
+			t.Fatalf("ReverseTransform(%v): %v", ts, err)
+		}
+		if back["created_at"] != "2024-03-01T10:00:00.000Z" {
+			t.Errorf("ReverseTransform(%#v) = %#v", ts, back["created_at"])
+		}
+	}
+}
+
+func TestDateValidation(t *testing.T) {
+	for _, expr := range []string{"date(unix)", "date(unix, nope)", "date(unix, iso, Mars/Olympus)"} {
+		if err := ValidatePipeline(expr); err == nil {
+			t.Errorf("ValidatePipeline(%s) succeeded, want an error", expr)
+		}
+	}
+	if err := ValidatePipeline(`date("2006-01-02 15:04", unix_ms, Asia/Bangkok)`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMergeMappings(t *testing.T) {
+	inherited := []*models.FieldMapping{
+		{PlatformID: "default", SourceField: "title", TargetField: "name"},
+		{PlatformID: "default", SourceField: "sku", TargetField: "sku"},
+		{PlatformID: "default", SourceField: "price", TargetField: "price", Condition: `currency == "USD"`},
+		{PlatformID: "default", SourceField: "handle", TargetField: "slug"},
+	}
+	own := []*models.FieldMapping{
+		{PlatformID: "shopee", SourceField: "sku", TargetField: "item_sku"},
+		{PlatformID: "shopee", SourceField: "handle", Unset: true},
+		{PlatformID: "shopee", SourceField: "price", TargetField: "price_thb"},
+		{PlatformID: "shopee", SourceField: "weight", Unset: true},
+	}
+
+	var got []string
+	for _, mapping := range mergeMappings(inherited, own) {
+		got = append(got, mapping.PlatformID+":"+mapping.SourceField+"->"+mapping.TargetField)
+	}
+
+	want := []string{
+		"default:title->name",
+		"shopee:sku->item_sku",
+		"default:price->price",
+		"shopee:price->price_thb",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("mergeMappings = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mergeMappings[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPreviewTracesProposedMappings(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "title", TargetField: "name"},
+	)
+
+	proposed := []*models.FieldMapping{
+		{SourceField: "title", TargetField: "item_name", Transform: "trim|uppercase", IsActive: true},
+		{SourceField: "price", TargetField: "price", Transform: "cents_to_dollars", IsActive: true},
+		{SourceField: "sku", TargetField: "item_sku", Required: true, IsActive: true},
+		{SourceField: "weight", TargetField: "weight", Condition: `type == "physical"`, IsActive: true},
+		{SourceField: "amount", TargetField: "amount", Transform: "convert_currency(currency, USD)", IsActive: true},
+	}
+	data := map[string]interface{}{
+		"title":  " shirt ",
+		"price":  json.Number("1999"),
+		"weight": 1,
+		"amount": json.Number("10"),
+	}
+
+	preview, err := m.Preview("test", "", "product", data, false, proposed)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+
+	if got := preview.Output["item_name"]; got != "SHIRT" {
+		t.Errorf("item_name = %v, want SHIRT", got)
+	}
+	if _, ok := preview.Output["name"]; ok {
+		t.Errorf("stored mapping was used instead of the proposed ones")
+	}
+
+	wantStatus := []string{StatusMapped, StatusMapped, StatusMissingRequired, StatusSkipped, StatusError}
+	if len(preview.Trace) != len(wantStatus) {
+		t.Fatalf("got %d trace entries, want %d", len(preview.Trace), len(wantStatus))
+	}
+	for i, want := range wantStatus {
+		if got := preview.Trace[i].Status; got != want {
+			t.Errorf("trace[%d] (%s) status = %s, want %s", i, preview.Trace[i].SourceField, got, want)
+		}
+	}
+
+	title := preview.Trace[0].Values
+	if len(title) != 1 || title[0].Read != " shirt " || len(title[0].Steps) != 2 || title[0].Steps[0].Output != "shirt" || title[0].Written != "SHIRT" {
+		t.Errorf("title trace = %+v", title)
+	}
+
+	if len(preview.Skipped) != 1 || preview.Skipped[0] != "weight" {
+		t.Errorf("skipped = %v, want [weight]", preview.Skipped)
+	}
+	if len(preview.Missing) != 1 || preview.Missing[0] != "sku" {
+		t.Errorf("missing = %v, want [sku]", preview.Missing)
+	}
+	if len(preview.Errors) != 2 {
+		t.Errorf("errors = %v, want the currency error and the missing required field", preview.Errors)
+	}
+}
+
+func TestInvalidateMappingsFollowsInheritance(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, nil, nil)
+	now := time.Now()
+	m.cache["default::product"] = &cachedMappings{entityType: "product", platforms: []string{"default"}, fetchedAt: now}
+	m.cache["shopee_th::product"] = &cachedMappings{entityType: "product", platforms: []string{"shopee_th", "shopee", "default"}, fetchedAt: now}
+	m.cache["shopee_th:shop_001:product"] = &cachedMappings{entityType: "product", platforms: []string{"shopee_th", "shopee", "default"}, fetchedAt: now}
+	m.cache["shopee::order"] = &cachedMappings{entityType: "order", platforms: []string{"shopee"}, fetchedAt: now}
+	m.cache["lazada::product"] = &cachedMappings{entityType: "product", platforms: []string{"lazada"}, fetchedAt: now}
+
+	var published [][]byte
+	m.SetBroadcaster(broadcasterFunc(func(topic string, payload []byte) error {
+		published = append(published, payload)
+		return nil
+	}), "mappings/invalidate")
+
+	m.InvalidateMappings("shopee", "product")
+
+	for _, key := range []string{"shopee_th::product", "shopee_th:shop_001:product"} {
+		if _, ok := m.cache[key]; ok {
+			t.Errorf("%s should have been invalidated", key)
+		}
+	}
+	for _, key := range []string{"default::product", "shopee::order", "lazada::product"} {
+		if _, ok := m.cache[key]; !ok {
+			t.Errorf("%s should have been kept", key)
+		}
+	}
+	if len(published) != 1 {
+		t.Fatalf("published %d invalidations, want 1", len(published))
+	}
+
+	// Our own broadcast coming back is ignored; another instance's is applied
+	m.cache["shopee_th::product"] = &cachedMappings{entityType: "product", platforms: []string{"shopee_th", "shopee", "default"}, fetchedAt: now}
+	m.HandleInvalidation(published[0])
+	if _, ok := m.cache["shopee_th::product"]; !ok {
+		t.Errorf("own invalidation was applied again")
+	}
+	m.HandleInvalidation([]byte(`{"origin":"other","kind":"mappings","platform_id":"shopee"}`))
+	if _, ok := m.cache["shopee::order"]; ok {
+		t.Errorf("shopee::order should have been invalidated by another instance")
+	}
+	if _, ok := m.cache["lazada::product"]; !ok {
+		t.Errorf("lazada::product should have been kept")
+	}
+}
+
+type broadcasterFunc func(topic string, payload []byte) error
+
+func (f broadcasterFunc) PublishRaw(topic string, payload []byte) error {
+	return f(topic, payload)
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	stored := []*models.FieldMapping{
+		{PlatformID: "shopee", EntityType: "product", SourceField: "title", TargetField: "item_name", Transform: `trim|pad_left(8, "0")`, IsActive: true},
+		{PlatformID: "shopee", EntityType: "product", SourceField: "status", TargetField: "item_status", DefaultValue: `{"code": 1, "label": "draft"}`, Required: true, IsActive: true},
+		{PlatformID: "shopee", EntityType: "product", ShopID: "shop_001", SourceField: "handle", Unset: true, IsActive: true},
+		{PlatformID: "shopee", EntityType: "order", SourceField: "total", TargetField: "amount", Condition: `currency == "USD"`, DefaultValue: "0.10", IsActive: false},
+	}
+
+	for _, format := range []string{BundleYAML, BundleJSON} {
+		bundle, err := NewBundle("shopee", stored)
+		if err != nil {
+			t.Fatalf("NewBundle: %v", err)
+		}
+		data, err := bundle.Encode(format)
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", format, err)
+		}
+
+		parsed, err := ParseBundle(data)
+		if err != nil {
+			t.Fatalf("ParseBundle(%s): %v", format, err)
+		}
+		mappings, err := parsed.FieldMappings()
+		if err != nil {
+			t.Fatalf("FieldMappings(%s): %v", format, err)
+		}
+
+		if plan := PlanBundle(stored, mappings, true); len(plan) != 0 {
+			t.Errorf("%s round trip changed mappings: %+v", format, plan)
+		}
+	}
+}
+
+func TestPlanBundlePrune(t *testing.T) {
+	stored := []*models.FieldMapping{
+		{PlatformID: "shopee", EntityType: "product", SourceField: "title", TargetField: "name", IsActive: true},
+		{PlatformID: "shopee", EntityType: "product", SourceField: "weight", TargetField: "weight", IsActive: true},
+		{PlatformID: "shopee", EntityType: "product", ShopID: "shop-1", SourceField: "handle", TargetField: "slug", IsActive: true},
+		{PlatformID: "shopee", EntityType: "order", SourceField: "total", TargetField: "amount", IsActive: true},
+	}
+	bundle, err := ParseBundle([]byte(`
+platform_id: shopee
+mappings:
+  - entity_type: product
+    source_field: title
+    target_field: item_name
+  - entity_type: product
+    source_field: sku
+    target_field: item_sku
+`))
+	if err != nil {
+		t.Fatalf("ParseBundle: %v", err)
+	}
+	mappings, err := bundle.FieldMappings()
+	if err != nil {
+		t.Fatalf("FieldMappings: %v", err)
+	}
+
+	plan := PlanBundle(stored, mappings, false)
+	if diff := plan["product"]; diff == nil || len(diff.Added) != 1 || len(diff.Changed) != 1 || len(diff.Removed) != 0 {
+		t.Errorf("product diff = %+v, want one added and one changed", diff)
+	}
+	if _, ok := plan["order"]; ok {
+		t.Errorf("without prune the order mappings should be kept")
+	}
+
+	// Prune covers the whole platform, including shops and entity types the
+	// bundle leaves out
+	plan = PlanBundle(stored, mappings, true)
+	if diff := plan["product"]; diff == nil || len(diff.Removed) != 2 || diff.Removed[0].SourceField != "weight" || diff.Removed[1].SourceField != "handle" {
+		t.Errorf("product diff = %+v, want weight and the shop-1 handle removed", diff)
+	}
+	if diff := plan["order"]; diff == nil || len(diff.Removed) != 1 {
+		t.Errorf("order diff = %+v, want one removed", diff)
+	}
+
+	sources := func(mappings []*models.FieldMapping) []string {
+		out := []string{}
+		for _, mapping := range mappings {
+			out = append(out, mapping.ShopID+":"+mapping.SourceField+"->"+mapping.TargetField)
+		}
+		return out
+	}
+
+	imported := ImportedMappings(stored, mappings, false)
+	if got, want := sources(imported["product"]), []string{":title->item_name", ":weight->weight", "shop-1:handle->slug", ":sku->item_sku"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("imported = %v, want %v", got, want)
+	}
+	imported = ImportedMappings(stored, mappings, true)
+	if got, want := sources(imported["product"]), []string{":title->item_name", ":sku->item_sku"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("imported with prune = %v, want %v", got, want)
+	}
+	if _, ok := imported["order"]; ok {
+		t.Errorf("imported = %v, want no order drafts left", imported)
+	}
+}
+
+func TestLintMappings(t *testing.T) {
+	mappings := []*models.FieldMapping{
+		{SourceField: "title", TargetField: "name", IsActive: true},
+		{SourceField: "handle", TargetField: "name", IsActive: true},
+		{SourceField: "sku", TargetField: "items.0.sku", IsActive: true},
+		{SourceField: "barcode", TargetField: "items.barcode", IsActive: true},
+		{SourceField: "created_at", TargetField: "created", Transform: "date(rfc3339, iso_date)", IsActive: true},
+		{SourceField: "status", TargetField: "state", Transform: "shout", IsActive: true},
+		{SourceField: "weight", TargetField: "name", IsActive: false},
+	}
+
+	codes := make(map[string]string)
+	for _, issue := range LintMappings(mappings) {
+		codes[issue.Code] = issue.Severity
+		if issue.Code == LintTargetCollision && !issue.Involves(MappingKey{SourceField: "handle"}) {
+			t.Errorf("collision %q should involve handle", issue.Message)
+		}
+	}
+
+	want := map[string]string{
+		LintTargetCollision:  LintError,
+		LintPathConflict:     LintError,
+		LintNotInvertible:    LintWarning,
+		LintUnknownTransform: LintError,
+	}
+	for code, severity := range want {
+		if codes[code] != severity {
+			t.Errorf("%s severity = %q, want %q", code, codes[code], severity)
+		}
+	}
+	if len(codes) != len(want) {
+		t.Errorf("issue codes = %v, want %v", codes, want)
+	}
+}
+
+func TestLintDrafts(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, nil, nil)
+
+	// The shop's handle mapping only collides once merged over the
+	// platform-wide set; inactive drafts are ignored
+	drafts := []*models.FieldMapping{
+		{SourceField: "title", TargetField: "name", IsActive: true},
+		{SourceField: "status", TargetField: "state", Transform: "shout", IsActive: true},
+		{ShopID: "shop-1", SourceField: "handle", TargetField: "name", IsActive: true},
+		{ShopID: "shop-2", SourceField: "status", TargetField: "state", Transform: "shout", IsActive: true},
+		{ShopID: "shop-3", SourceField: "slug", TargetField: "name", IsActive: false},
+	}
+
+	issues, err := m.LintDrafts("test", "product", drafts)
+	if err != nil {
+		t.Fatalf("LintDrafts: %v", err)
+	}
+
+	codes := make(map[string]int)
+	for _, issue := range issues {
+		codes[issue.Code]++
+	}
+	want := map[string]int{LintTargetCollision: 1, LintUnknownTransform: 1}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("issue codes = %v, want %v", codes, want)
+	}
+	if got := LintErrors(issues); len(got) != 2 {
+		t.Errorf("LintErrors = %v, want 2 messages", got)
+	}
+
+	issues, err = m.LintDrafts("test", "product", drafts[:1])
+	if err != nil {
+		t.Fatalf("LintDrafts: %v", err)
+	}
+	if len(issues) != 0 || LintErrors(issues) != nil {
+		t.Errorf("issues = %+v, want none", issues)
+	}
+}
+
+func TestDiffMappings(t *testing.T) {
+	published := []*models.FieldMapping{
+		{SourceField: "title", TargetField: "name", IsActive: true},
+		{SourceField: "price", TargetField: "amount", Transform: "cents_to_dollars", IsActive: true},
+		{SourceField: "status", TargetField: "state", Condition: "type == digital", IsActive: true},
+		{SourceField: "status", TargetField: "state", IsActive: true},
+		{ShopID: "shop-1", SourceField: "title", TargetField: "name", IsActive: true},
+	}
+
+	tests := []struct {
+		name    string
+		drafts  []*models.FieldMapping
+		added   []MappingKey
+		removed []MappingKey
+		changed map[MappingKey][]string
+	}{
+		{
+			name:   "unchanged",
+			drafts: published,
+		},
+		{
+			name: "added, removed and changed",
+			drafts: []*models.FieldMapping{
+				{SourceField: "title", TargetField: "item_name", IsActive: true},
+				{SourceField: "price", TargetField: "amount", Transform: "round(2)", Required: true, IsActive: true},
+				{SourceField: "status", TargetField: "state", Condition: "type == digital", IsActive: false},
+				{SourceField: "status", TargetField: "state", IsActive: true},
+				{SourceField: "sku", TargetField: "item_sku", IsActive: true},
+			},
+			added:   []MappingKey{{SourceField: "sku"}},
+			removed: []MappingKey{{ShopID: "shop-1", SourceField: "title"}},
+			changed: map[MappingKey][]string{
+				{SourceField: "title"}:                                {"target_field"},
+				{SourceField: "price"}:                                {"transform", "required"},
+				{SourceField: "status", Condition: "type == digital"}: {"is_active"},
+			},
+		},
+		{
+			name: "matched by shop and condition",
+			drafts: []*models.FieldMapping{
+				{ShopID: "shop-2", SourceField: "title", TargetField: "name", IsActive: true},
+				{SourceField: "status", TargetField: "state", Condition: "type == physical", IsActive: true},
+			},
+			added: []MappingKey{
+				{ShopID: "shop-2", SourceField: "title"},
+				{SourceField: "status", Condition: "type == physical"},
+			},
+			removed: []MappingKey{
+				{SourceField: "title"},
+				{SourceField: "price"},
+				{SourceField: "status", Condition: "type == digital"},
+				{SourceField: "status"},
+				{ShopID: "shop-1", SourceField: "title"},
+			},
+		},
+	}
+
+	keys := func(mappings []*models.FieldMapping) []MappingKey {
+		out := []MappingKey{}
+		for _, mapping := range mappings {
+			out = append(out, keyOf(mapping))
+		}
+		return out
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffMappings(published, tt.drafts)
+
+			if want := append([]MappingKey{}, tt.added...); !reflect.DeepEqual(keys(diff.Added), want) {
+				t.Errorf("added = %v, want %v", keys(diff.Added), want)
+			}
+			if want := append([]MappingKey{}, tt.removed...); !reflect.DeepEqual(keys(diff.Removed), want) {
+				t.Errorf("removed = %v, want %v", keys(diff.Removed), want)
+			}
+			changed := make(map[MappingKey][]string)
+			for _, change := range diff.Changed {
+				changed[change.Key] = change.Fields
+			}
+			if tt.changed == nil {
+				tt.changed = map[MappingKey][]string{}
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if diff.Empty() != (len(tt.added)+len(tt.removed)+len(tt.changed) == 0) {
+				t.Errorf("Empty() = %v", diff.Empty())
+			}
+		})
+	}
+
+	// Diffing against a version that was never published adds every mapping
+	if diff := DiffMappings(nil, published); len(diff.Added) != len(published) || len(diff.Removed) != 0 {
+		t.Errorf("diff from nothing = %+v, want every mapping added", diff)
+	}
+}
+
+func TestCompiledDefaultValueIsNotShared(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "attributes", TargetField: "attributes", DefaultValue: `{"brand": "none"}`},
+		&models.FieldMapping{SourceField: "color", TargetField: "attributes.color"},
+	)
+
+	first, err := m.Transform("test", "", "product", map[string]interface{}{"color": "blue"})
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if got := getNestedValue(first, "attributes.color"); got != "blue" {
+		t.Fatalf("attributes.color = %v, want blue", got)
+	}
+
+	second, err := m.Transform("test", "", "product", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if got := getNestedValue(second, "attributes.color"); got != nil {
+		t.Errorf("attributes.color = %v, want the default without the previous call's color", got)
+	}
+}
+
+func TestNestedEntityMappings(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "title", TargetField: "item_name"},
+		&models.FieldMapping{SourceField: "variants", TargetField: "models", Transform: "nested(variant)"},
+		&models.FieldMapping{SourceField: "images.*", TargetField: "image.*", Transform: "nested(image)"},
+	)
+	primeTestMappings(m, "variant",
+		&models.FieldMapping{SourceField: "sku", TargetField: "model_sku"},
+		&models.FieldMapping{SourceField: "price", TargetField: "price_info.original_price", Transform: "cents_to_dollars"},
+	)
+	primeTestMappings(m, "image",
+		&models.FieldMapping{SourceField: "url", TargetField: "image_url"},
+	)
+
+	product := map[string]interface{}{
+		"title": "Shirt",
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "S-1", "price": json.Number("1999")},
+			map[string]interface{}{"sku": "S-2", "price": json.Number("2499")},
+		},
+		"images": []interface{}{
+			map[string]interface{}{"url": "https://example.com/1.jpg"},
+		},
+	}
+
+	out, err := m.Transform("test", "", "product", product)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if got := getNestedValue(out, "models.1.price_info.original_price"); got != json.Number("24.99") {
+		t.Errorf("models.1.price_info.original_price = %v, want 24.99", got)
+	}
+	if got := getNestedValue(out, "image.0.image_url"); got != "https://example.com/1.jpg" {
+		t.Errorf("image.0.image_url = %v", got)
+	}
+
+	back, err := m.ReverseTransform("test", "", "product", out)
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got := getNestedValue(back, "variants.1.price"); got != json.Number("2499") {
+		t.Errorf("variants.1.price = %v, want 2499", got)
+	}
+	if got := getNestedValue(back, "variants.0.sku"); got != "S-1" {
+		t.Errorf("variants.0.sku = %v, want S-1", got)
+	}
+
+	primeTestMappings(m, "variant",
+		&models.FieldMapping{SourceField: "options", TargetField: "options", Transform: "nested(product)"},
+	)
+	product["variants"] = []interface{}{map[string]interface{}{"options": map[string]interface{}{}}}
+	if _, err := m.Transform("test", "", "product", product); err == nil || !strings.Contains(err.Error(), "product -> variant -> product") {
+		t.Errorf("Transform error = %v, want a nested mapping cycle", err)
+	}
+}
+
+func TestNestedMissingFieldPaths(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "variants", TargetField: "models", Transform: "nested(variant)"},
+		&models.FieldMapping{SourceField: "images.*", TargetField: "image.*", Transform: "nested(image)"},
+		&models.FieldMapping{SourceField: "dimensions", TargetField: "package", Transform: "nested(dimension)"},
+	)
+	primeTestMappings(m, "variant",
+		&models.FieldMapping{SourceField: "sku", TargetField: "model_sku", Required: true},
+		&models.FieldMapping{SourceField: "options", TargetField: "tier", Transform: "nested(option)"},
+	)
+	primeTestMappings(m, "option",
+		&models.FieldMapping{SourceField: "name", TargetField: "tier_name", Required: true},
+	)
+	primeTestMappings(m, "image",
+		&models.FieldMapping{SourceField: "url", TargetField: "image_url", Required: true},
+	)
+	primeTestMappings(m, "dimension",
+		&models.FieldMapping{SourceField: "weight", TargetField: "weight", Required: true},
+	)
+
+	tests := []struct {
+		name    string
+		product map[string]interface{}
+		missing []string
+	}{
+		{
+			name: "array element",
+			product: map[string]interface{}{"variants": []interface{}{
+				map[string]interface{}{"sku": "S-1"},
+				map[string]interface{}{"title": "no sku"},
+			}},
+			missing: []string{"variants.1.sku"},
+		},
+		{
+			name: "nested twice",
+			product: map[string]interface{}{"variants": []interface{}{
+				map[string]interface{}{"sku": "S-1", "options": map[string]interface{}{}},
+			}},
+			missing: []string{"variants.0.options.name"},
+		},
+		{
+			name: "wildcard element",
+			product: map[string]interface{}{"images": []interface{}{
+				map[string]interface{}{"url": "https://example.com/1.jpg"},
+				map[string]interface{}{},
+			}},
+			missing: []string{"images.1.url"},
+		},
+		{
+			name:    "object",
+			product: map[string]interface{}{"dimensions": map[string]interface{}{"height": json.Number("3")}},
+			missing: []string{"dimensions.weight"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Transform("test", "", "product", tt.product)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Transform error = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Missing, tt.missing) {
+				t.Errorf("Missing = %v, want %v", validationErr.Missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestTextAndCollectionTransforms(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "items.*.quantity", TargetField: "total_quantity", Transform: "sum"},
+		&models.FieldMapping{SourceField: "items", TargetField: "active_items", Transform: `filter(status == "active")|count`},
+		&models.FieldMapping{SourceField: "items", TargetField: "top_sku", Transform: "sort_by(price, desc)|first|sum(price)"},
+		&models.FieldMapping{SourceField: "items.*.sku", TargetField: "skus", Transform: "lowercase"},
+		&models.FieldMapping{SourceField: "tags", TargetField: "tag_list", Transform: `split(",")|trim|filter(value != "")`},
+		&models.FieldMapping{SourceField: "title", TargetField: "slug", Transform: "slugify"},
+		&models.FieldMapping{SourceField: "title", TargetField: "short_title", Transform: `truncate(10, "...")`},
+		&models.FieldMapping{SourceField: "reference", TargetField: "reference_number", Transform: `regex_extract("^[A-Z]+-([0-9]+)$")`},
+		&models.FieldMapping{SourceField: "phone", TargetField: "phone", Transform: `regex_replace("[^0-9+]", "")`},
+	)
+
+	order := map[string]interface{}{
+		"title":     "Blue Shirt (XL) & Co",
+		"reference": "ORD-0042",
+		"phone":     "+66 (81) 234-5678",
+		"tags":      "sale, summer,,new",
+		"items": []interface{}{
+			map[string]interface{}{"sku": "A-1", "quantity": json.Number("2"), "price": json.Number("10.5"), "status": "active"},
+			map[string]interface{}{"sku": "B-2", "quantity": json.Number("3"), "price": json.Number("30"), "status": "cancelled"},
+		},
+	}
+
+	out, err := m.Transform("test", "", "order", order)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	want := map[string]string{
+		"total_quantity":   "5",
+		"active_items":     "1",
+		"top_sku":          "30",
+		"skus":             "[a-1 b-2]",
+		"tag_list":         "[sale summer new]",
+		"slug":             "blue-shirt-xl-co",
+		"short_title":      "Blue Sh...",
+		"reference_number": "0042",
+		"phone":            "+66812345678",
+	}
+	for path, value := range want {
+		if got := fmt.Sprint(getNestedValue(out, path)); got != value {
+			t.Errorf("%s = %s, want %s", path, got, value)
+		}
+	}
+
+	back, err := m.ReverseTransform("test", "", "order", out)
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got := back["tags"]; got != "sale,summer,new" {
+		t.Errorf("tags = %v, want the split list joined back", got)
+	}
+	if got := fmt.Sprint(getNestedValue(back, "items.1.sku")); got != "b-2" {
+		t.Errorf("items.1.sku = %s, want b-2", got)
+	}
+	if got := getNestedValue(back, "items.0.quantity"); got != nil {
+		t.Errorf("items.0.quantity = %v, want sum not to be reversed", got)
+	}
+}
+
+// benchmarkOrder returns an order with n line items and the mappings of a
+// typical marketplace order sync
+func benchmarkOrder(n int) (map[string]interface{}, []*models.FieldMapping) {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"variant_id":  "variant_" + strconv.Itoa(i),
+			"variant_sku": "SKU-" + strconv.Itoa(i),
+			"title":       "  Blue Shirt ",
+			"quantity":    json.Number("2"),
+			"unit_price":  json.Number("1999"),
+		}
+	}
+	order := map[string]interface{}{
+		"id":            "order_01",
+		"email":         " Jane@Example.com ",
+		"currency_code": "usd",
+		"total":         json.Number(strconv.Itoa(3998 * n)),
+		"shipping_address": map[string]interface{}{
+			"first_name": "Jane",
+			"last_name":  "Doe",
+		},
+		"items": items,
+	}
+
+	mappings := []*models.FieldMapping{
+		{SourceField: "id", TargetField: "order_sn"},
+		{SourceField: "email", TargetField: "buyer_email", Transform: "trim|lowercase"},
+		{SourceField: "currency_code", TargetField: "currency", Transform: "uppercase"},
+		{SourceField: "total", TargetField: "total_amount", Transform: "cents_to_dollars"},
+		{SourceField: "{shipping_address.first_name} {shipping_address.last_name}", TargetField: "recipient_address.name"},
+		{SourceField: "items.*.variant_sku", TargetField: "item_list.*.model_sku"},
+		{SourceField: "items.*.title", TargetField: "item_list.*.item_name", Transform: "trim"},
+		{SourceField: "items.*.quantity", TargetField: "item_list.*.model_quantity_purchased", Transform: "int"},
+		{SourceField: "items.*.unit_price", TargetField: "item_list.*.model_discounted_price", Transform: "cents_to_dollars"},
+		{SourceField: "items.*.variant_id", TargetField: "item_list.*.model_id", Condition: `currency_code == "usd"`},
+	}
+	return order, mappings
+}
+
+func BenchmarkTransformLineItems(b *testing.B) {
+	for _, n := range []int{10, 100, 500} {
+		order, mappings := benchmarkOrder(n)
+		m := newTestMapper("order", mappings...)
+
+		b.Run(strconv.Itoa(n)+"/forward", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.Transform("test", "", "order", order); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "items/s")
+		})
+
+		platformOrder, err := m.Transform("test", "", "order", order)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(strconv.Itoa(n)+"/reverse", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.ReverseTransform("test", "", "order", platformOrder); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "items/s")
+		})
+	}
+}
+
+type testCoverageObserver struct {
+	samples []*CoverageSample
+}
+
+func (o *testCoverageObserver) ObserveCoverage(sample *CoverageSample) {
+	o.samples = append(o.samples, sample)
+}
+
+func TestCoverageSamplesUnmappedPaths(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "title", TargetField: "item_name"},
+		&models.FieldMapping{SourceField: "variants.*.sku", TargetField: "models.*.model_sku"},
+		&models.FieldMapping{SourceField: "images", TargetField: "images"},
+	)
+	observer := &testCoverageObserver{}
+	m.SetCoverageObserver(observer, 1)
+
+	product := map[string]interface{}{
+		"title": "Shirt",
+		"brand": nil,
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "S-1", "barcode": "123"},
+			map[string]interface{}{"sku": "S-2"},
+		},
+		"images": []interface{}{map[string]interface{}{"url": "https://example.com/1.jpg"}},
+	}
+	if _, err := m.Transform("test", "", "product", product); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if _, err := m.ReverseTransform("test", "", "product", map[string]interface{}{"item_name": "Shirt"}); err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+
+	if len(observer.samples) != 1 {
+		t.Fatalf("got %d samples, want 1 (reverse transforms are not sampled)", len(observer.samples))
+	}
+	sample := observer.samples[0]
+	if sample.PlatformID != "test" || sample.EntityType != "product" {
+		t.Errorf("sample scope = %s/%s", sample.PlatformID, sample.EntityType)
+	}
+
+	want := map[string]bool{
+		"brand":              false,
+		"images.*.url":       true,
+		"title":              true,
+		"variants.*.barcode": false,
+		"variants.*.sku":     true,
+	}
+	if len(sample.Paths) != len(want) {
+		t.Fatalf("paths = %+v", sample.Paths)
+	}
+	for _, path := range sample.Paths {
+		mapped, ok := want[path.Path]
+		if !ok {
+			t.Errorf("unexpected path %s", path.Path)
+			continue
+		}
+		if path.Mapped != mapped {
+			t.Errorf("%s mapped = %v, want %v", path.Path, path.Mapped, mapped)
+		}
+	}
+	if sample.Paths[3].Example != "123" {
+		t.Errorf("variants.*.barcode example = %v, want 123", sample.Paths[3].Example)
+	}
+}
+
+func TestCoverageRedactsExamplesMappedToPII(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "customer.email", TargetField: "buyer_email"},
+		&models.FieldMapping{SourceField: "shipping", TargetField: "recipient"},
+		&models.FieldMapping{SourceField: "{customer.first_name} {customer.last_name}", TargetField: "buyer_name"},
+		&models.FieldMapping{SourceField: "status", TargetField: "order_status"},
+	)
+	m.piiCache["test"] = &cachedPIIPolicy{rules: compilePIIRules([]models.PIIRule{
+		{Path: "buyer_email", Transform: "mask_email"},
+		{Path: "recipient.phone", Transform: "mask_phone"},
+		{Path: "buyer_name", Transform: "redact"},
+		{Path: "note", Transform: "redact"},
+	}), fetchedAt: time.Now()}
+	observer := &testCoverageObserver{}
+	m.SetCoverageObserver(observer, 1)
+
+	order := map[string]interface{}{
+		"status": "paid",
+		"note":   "leave at the door",
+		"customer": map[string]interface{}{
+			"email":      "jane@example.com",
+			"first_name": "Jane",
+			"last_name":  "Doe",
+			"tier":       "gold",
+		},
+		"shipping": map[string]interface{}{"phone": "+1 555-123-4567", "city": "Austin", "zip": nil},
+	}
+	if _, err := m.Transform("test", "", "order", order); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if len(observer.samples) != 1 {
+		t.Fatalf("got %d samples, want 1", len(observer.samples))
+	}
+
+	examples := make(map[string]interface{})
+	for _, path := range observer.samples[0].Paths {
+		examples[path.Path] = path.Example
+	}
+	want := map[string]interface{}{
+		"customer.email":      Redacted,
+		"customer.first_name": Redacted,
+		"customer.last_name":  Redacted,
+		"customer.tier":       "gold",
+		"note":                Redacted,
+		"shipping.city":       Redacted,
+		"shipping.phone":      Redacted,
+		"shipping.zip":        nil,
+		"status":              "paid",
+	}
+	if !reflect.DeepEqual(examples, want) {
+		t.Errorf("examples = %v, want %v", examples, want)
+	}
+}
+
+func TestSuggestMappings(t *testing.T) {
+	source := map[string]interface{}{
+		"title":      "Blue Shirt",
+		"handle":     "blue-shirt",
+		"status":     "published",
+		"created_at": "2024-03-01T10:00:00Z",
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "BS-1", "price": json.Number("1999"), "inventoryQty": json.Number("4")},
+		},
+		"discountable": true,
+	}
+	target := map[string]interface{}{
+		"item_name":   "Blue Shirt",
+		"item_status": "PUBLISHED",
+		"create_time": json.Number("1709287200"),
+		"model_list": []interface{}{
+			map[string]interface{}{"model_sku": "BS-1", "original_price": json.Number("19.99"), "inventory_quantity": json.Number("4")},
+		},
+		"is_pre_order": false,
+	}
+
+	got := SuggestMappings(source, target, []*models.FieldMapping{
+		{SourceField: "handle", TargetField: "item_slug", IsActive: true},
+	})
+
+	want := map[string]string{
+		"title":                   "item_name|",
+		"status":                  "item_status|uppercase",
+		"created_at":              "create_time|date(rfc3339, unix)",
+		"variants.*.sku":          "model_list.*.model_sku|",
+		"variants.*.price":        "model_list.*.original_price|cents_to_dollars",
+		"variants.*.inventoryQty": "model_list.*.inventory_quantity|",
+	}
+	suggested := make(map[string]string)
+	for _, s := range got.Mappings {
+		suggested[s.SourceField] = s.TargetField + "|" + s.Transform
+	}
+	for source, want := range want {
+		if suggested[source] != want {
+			t.Errorf("%s -> %q, want %q", source, suggested[source], want)
+		}
+	}
+	if len(got.Mappings) != len(want) {
+		t.Errorf("mappings = %+v", got.Mappings)
+	}
+	if len(got.UnmatchedSource) != 1 || got.UnmatchedSource[0] != "discountable" {
+		t.Errorf("unmatched source = %v, want [discountable] (handle is mapped)", got.UnmatchedSource)
+	}
+	if len(got.UnmatchedTarget) != 1 || got.UnmatchedTarget[0] != "is_pre_order" {
+		t.Errorf("unmatched target = %v, want [is_pre_order]", got.UnmatchedTarget)
+	}
+}
+
+func TestCompileSchemaRejectsInvalidSchemas(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`{"type": "object", "properties": {"price": {"type": "money"}}}`, "properties.price.type: value must be one of"},
+		{`{"minLength": -1}`, "minLength: must be >= 0"},
+		{`{"properties": {"sku": {"pattern": "("}}}`, "properties.sku.pattern: '(' is not valid 'regex'"},
+		{`{"$ref": "https://example.com/item.json"}`, "references to other documents are not supported"},
+		{`{"$ref": "#/$defs/missing"}`, "#/$defs/missing not found"},
+		{`[]`, "expected object or boolean, but got array"},
+		{`{"type": `, "invalid JSON"},
+	}
+	for _, tt := range tests {
+		_, err := CompileSchema([]byte(tt.schema))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileSchema(%s) error = %v, want %q", tt.schema, err, tt.want)
+		}
+	}
+
+	valid := []string{
+		`{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "Item", "type": "object"}`,
+		`{"$schema": "http://json-schema.org/draft-07/schema#", "type": "object"}`,
+		`{"properties": {"price": {"$ref": "#/$defs/money"}}, "$defs": {"money": {"oneOf": [{"type": "number"}, {"type": "string"}]}}}`,
+		`true`,
+	}
+	for _, schema := range valid {
+		if _, err := CompileSchema([]byte(schema)); err != nil {
+			t.Errorf("CompileSchema(%s): %v", schema, err)
+		}
+	}
+}
+
+func TestValidateOutputReportsPaths(t *testing.T) {
+	schema, err := CompileSchema([]byte(`{
+		"type": "object",
+		"required": ["item_name", "model_list"],
+		"additionalProperties": false,
+		"properties": {
+			"item_name": {"type": "string", "minLength": 1, "maxLength": 10},
+			"item_status": {"enum": ["NORMAL", "UNLIST"]},
+			"create_time": {"type": "integer"},
+			"model_list": {
+				"type": "array",
+				"minItems": 1,
+				"items": {
+					"type": "object",
+					"required": ["model_sku"],
+					"properties": {
+						"model_sku": {"type": "string", "pattern": "^[A-Z0-9-]+$"},
+						"original_price": {"type": "number", "exclusiveMinimum": 0}
+					}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("CompileSchema: %v", err)
+	}
+
+	m := newTestMapper("product")
+	m.schemaCache["test:product:forward"] = &cachedSchema{schema: schema, fetchedAt: time.Now()}
+
+	valid := map[string]interface{}{
+		"item_name":   "Shirt",
+		"create_time": json.Number("1709287200.0"),
+		"model_list": []interface{}{
+			map[string]interface{}{"model_sku": "BS-1", "original_price": json.Number("19.99")},
+		},
+	}
+	if err := m.ValidateOutput("test", "product", false, valid); err != nil {
+		t.Errorf("valid output: %v", err)
+	}
+
+	// Go values other than decoded JSON are validated in their JSON form
+	typed := map[string]interface{}{
+		"item_name":  "Shirt",
+		"model_list": []map[string]interface{}{{"model_sku": "BS-1", "original_price": 19.99}},
+	}
+	if err := m.ValidateOutput("test", "product", false, typed); err != nil {
+		t.Errorf("typed output: %v", err)
+	}
+
+	invalid := map[string]interface{}{
+		"item_name":   "A very long shirt name",
+		"item_status": "ACTIVE",
+		"create_time": json.Number("1709287200.5"),
+		"model_list": []interface{}{
+			map[string]interface{}{"model_sku": "BS-1", "original_price": json.Number("0")},
+			map[string]interface{}{"model_sku": "bs 2"},
+			map[string]interface{}{"original_price": "19.99"},
+		},
+		"brand": "Acme",
+	}
+	err = m.ValidateOutput("test", "product", false, invalid)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("ValidateOutput error = %v, want a *SchemaError", err)
+	}
+
+	want := []SchemaViolation{
+		{Path: "", Message: "additionalProperties 'brand' not allowed"},
+		{Path: "create_time", Message: "expected integer, but got number"},
+		{Path: "item_name", Message: "length must be <= 10, but got 22"},
+		{Path: "item_status", Message: `value must be one of "NORMAL", "UNLIST"`},
+		{Path: "model_list.0.original_price", Message: "must be > 0 but found 0"},
+		{Path: "model_list.1.model_sku", Message: "does not match pattern '^[A-Z0-9-]+$'"},
+		{Path: "model_list.2", Message: "missing properties: 'model_sku'"},
+		{Path: "model_list.2.original_price", Message: "expected number, but got string"},
+	}
+	if !reflect.DeepEqual(schemaErr.Violations, want) {
+		t.Errorf("violations = %+v\nwant %+v", schemaErr.Violations, want)
+	}
+
+	if err := m.ValidateOutput("test", "product", true, invalid); err != nil {
+		t.Errorf("reverse output has no schema, got %v", err)
+	}
+}
+
+func TestPIITransforms(t *testing.T) {
+	SetHashSalts(map[string]string{"customers": "s3cret"})
+	defer SetHashSalts(nil)
+
+	cases := []struct {
+		pipeline string
+		in       interface{}
+		want     interface{}
+	}{
+		{"mask_email", "jane.doe@example.com", "j***@example.com"},
+		{"mask_email", "not-an-email", "***"},
+		{"mask_phone", "+1 555-123-4567", "+* ***-***-4567"},
+		{"mask_phone(2)", "0812345678", "********78"},
+		{"mask_phone", "123", "***"},
+		{"redact", map[string]interface{}{"line1": "1 Main St"}, Redacted},
+		{"redact", nil, nil},
+	}
+	for _, c := range cases {
+		steps, err := parsePipeline(c.pipeline)
+		if err != nil {
+			t.Fatalf("%s: %v", c.pipeline, err)
+		}
+		got, err := applySteps(&Context{}, c.in, steps)
+		if err != nil {
+			t.Fatalf("%s(%v): %v", c.pipeline, c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s(%v) = %v, want %v", c.pipeline, c.in, got, c.want)
+		}
+	}
+
+	steps, err := parsePipeline("hash_sha256(customers)")
+	if err != nil {
+		t.Fatalf("hash_sha256: %v", err)
+	}
+	first, _ := applySteps(&Context{}, "jane.doe@example.com", steps)
+	second, _ := applySteps(&Context{}, "jane.doe@example.com", steps)
+	other, _ := applySteps(&Context{}, "john@example.com", steps)
+	if first != second || first == other || len(first.(string)) != 64 {
+		t.Errorf("hash_sha256 = %v, %v, %v, want a stable 64 character hash per value", first, second, other)
+	}
+
+	if err := ValidatePipeline("hash_sha256(unknown)"); err == nil {
+		t.Error("hash_sha256 with an unknown salt validated")
+	}
+}
+
+func TestPIIPolicyMasksOutput(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "buyer_email", TargetField: "customer.email"},
+	)
+	m.policyCache["test:order"] = &cachedPolicy{policy: &models.MappingPolicy{UnmappedFields: models.UnmappedPassthrough}, fetchedAt: time.Now()}
+	m.piiCache["test"] = &cachedPIIPolicy{rules: compilePIIRules([]models.PIIRule{
+		{Path: "customer.email", Transform: "mask_email"},
+		{Path: "addresses.*.phone", Transform: "mask_phone"},
+		{Path: "note", Transform: "redact"},
+		{Path: "tax_id", Transform: "hash_sha256(missing)"},
+		{Path: "addresses.*.zip", Transform: "hash_sha256(missing)"},
+	}), fetchedAt: time.Now()}
+
+	order := map[string]interface{}{
+		"buyer_email": "jane.doe@example.com",
+		"note":        "Leave at the door",
+		"tax_id":      "1234567890",
+		"addresses": []interface{}{
+			map[string]interface{}{"city": "Bangkok", "phone": "0812345678", "zip": "10110"},
+			map[string]interface{}{"city": "Chiang Mai", "phone": "0898765432"},
+		},
+	}
+
+	out, err := m.Transform("test", "", "order", order)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"customer.email":    "j***@example.com",
+		"addresses.0.phone": "******5678",
+		"addresses.1.phone": "******5432",
+		"addresses.1.city":  "Chiang Mai",
+		"addresses.0.city":  "Bangkok",
+		"note":              Redacted,
+	}
+	for path, value := range want {
+		if got := getNestedValue(out, path); got != value {
+			t.Errorf("%s = %v, want %v", path, got, value)
+		}
+	}
+
+	// Values a rule fails on are left out rather than sent as null
+	if value, ok := out["tax_id"]; ok {
+		t.Errorf("tax_id = %v, want the key removed", value)
+	}
+	if address, _ := getNestedValue(out, "addresses.0").(map[string]interface{}); address == nil {
+		t.Errorf("addresses.0 = %v, want an object", getNestedValue(out, "addresses.0"))
+	} else if value, ok := address["zip"]; ok {
+		t.Errorf("addresses.0.zip = %v, want the key removed", value)
+	}
+
+	if got := getNestedValue(order, "addresses.0.phone"); got != "0812345678" {
+		t.Errorf("input addresses.0.phone = %v, want it unchanged", got)
+	}
+
+	back, err := m.ReverseTransform("test", "", "order", map[string]interface{}{"customer": map[string]interface{}{"email": "jane.doe@example.com"}})
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got := back["buyer_email"]; got != "jane.doe@example.com" {
+		t.Errorf("reverse buyer_email = %v, want the policy not to apply", got)
+	}
+
+	if err := ValidatePIIPolicy([]models.PIIRule{{Path: "note", Transform: "redact"}, {Path: "note", Transform: "mask_email"}}); err == nil {
+		t.Error("policy listing a path twice validated")
+	}
+}
+
+func TestTransformPipelines(t *testing.T) {
+	tests := []struct {
+		pipeline string
+		value    interface{}
+		want     interface{}
+		// back is the ReverseTransform of want, or nil when it is want itself
+		back interface{}
+	}{
+		{"trim|uppercase", "  shirt ", "SHIRT", nil},
+		{`string|pad_left(8, "0")`, json.Number("42"), "00000042", "42"},
+		{`pad_left(4, "*")`, "né", "**né", "né"},
+		// pad_left is lossy: leading pads of the value itself are stripped too
+		{`pad_left(5, "0")`, "00123", "00123", "123"},
+		{"cents_to_dollars|round(1)", json.Number("1999"), json.Number("20"), json.Number("2000")},
+		{"int|string", "7", "7", 7},
+		{"", "as is", "as is", nil},
+	}
+
+	for _, tt := range tests {
+		m := newTestMapper("product",
+			&models.FieldMapping{SourceField: "value", TargetField: "out", Transform: tt.pipeline},
+		)
+
+		out, err := m.Transform("test", "", "product", map[string]interface{}{"value": tt.value})
+		if err != nil {
+			t.Fatalf("Transform(%q): %v", tt.pipeline, err)
+		}
+		if got := out["out"]; got != tt.want {
+			t.Errorf("Transform(%q) = %#v, want %#v", tt.pipeline, got, tt.want)
+		}
+
+		back, err := m.ReverseTransform("test", "", "product", out)
+		if err != nil {
+			t.Fatalf("ReverseTransform(%q): %v", tt.pipeline, err)
+		}
+		want := tt.back
+		if want == nil {
+			want = tt.want
+		}
+		if got := back["value"]; got != want {
+			t.Errorf("ReverseTransform(%q) = %#v, want %#v", tt.pipeline, got, want)
+		}
+	}
+
+	issues := LintMappings([]*models.FieldMapping{
+		{SourceField: "sku", TargetField: "sku", Transform: `pad_left(5, "0")`, IsActive: true},
+	})
+	if len(issues) != 1 || issues[0].Code != LintNotInvertible {
+		t.Errorf("lint issues = %+v, want pad_left reported as not_invertible", issues)
+	}
+}
+
+func TestPipelineValidation(t *testing.T) {
+	tests := []struct {
+		pipeline string
+		valid    bool
+	}{
+		{"trim|lowercase", true},
+		{` trim | pad_left(4, "0") `, true},
+		{`pad_left(4, "|")|trim`, true},
+		{"trim||lowercase", false},
+		{"shout", false},
+		{"pad_left", false},
+		{"pad_left(4", false},
+		{"trim(1)", false},
+		{"round(two)", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePipeline(tt.pipeline)
+		if tt.valid && err != nil {
+			t.Errorf("ValidatePipeline(%q): %v", tt.pipeline, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidatePipeline(%q) succeeded, want an error", tt.pipeline)
+		}
+	}
+}
+
+func TestPipelineCompilesArguments(t *testing.T) {
+	steps, err := parsePipeline(`regex_replace("[^0-9]", "")|filter(value != "")|trim`)
+	if err != nil {
+		t.Fatalf("parsePipeline: %v", err)
+	}
+	if _, ok := steps[0].compiled.(*regexp.Regexp); !ok {
+		t.Errorf("regex_replace compiled = %T, want *regexp.Regexp", steps[0].compiled)
+	}
+	if _, ok := steps[1].compiled.(condition); !ok {
+		t.Errorf("filter compiled = %T, want a condition", steps[1].compiled)
+	}
+	if steps[2].compiled != nil {
+		t.Errorf("trim compiled = %v, want nil", steps[2].compiled)
+	}
+
+	ctx := &Context{}
+	got, err := applySteps(ctx, []interface{}{"a1", "b", "2c"}, steps)
+	if err != nil {
+		t.Fatalf("applySteps: %v", err)
+	}
+	if want := []interface{}{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applySteps = %v, want %v", got, want)
+	}
+
+	// Outside a compiled pipeline the arguments are compiled on each call
+	impl, _ := GetTransform("regex_extract")
+	if got, err := impl.Forward(nil, "ORD-42", []string{"[0-9]+"}); err != nil || got != "42" {
+		t.Errorf("regex_extract = %v, %v, want 42", got, err)
+	}
+}
+
+func TestWildcardPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *models.FieldMapping
+		input   map[string]interface{}
+		want    string
+		// back is the ReverseTransform of want
+		back string
+	}{
+		{
+			name:    "element by element",
+			mapping: &models.FieldMapping{SourceField: "variants.*.sku", TargetField: "models.*.model_sku"},
+			input:   map[string]interface{}{"variants": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B"}}},
+			want:    `{"models":[{"model_sku":"A"},{"model_sku":"B"}]}`,
+			back:    `{"variants":[{"sku":"A"},{"sku":"B"}]}`,
+		},
+		{
+			name:    "collect into an array",
+			mapping: &models.FieldMapping{SourceField: "variants.*.sku", TargetField: "skus"},
+			input:   map[string]interface{}{"variants": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{}, map[string]interface{}{"sku": "C"}}},
+			want:    `{"skus":["A","C"]}`,
+			back:    `{"variants":[{"sku":"A"},{"sku":"C"}]}`,
+		},
+		{
+			name:    "spread over elements",
+			mapping: &models.FieldMapping{SourceField: "skus", TargetField: "models.*.model_sku"},
+			input:   map[string]interface{}{"skus": []interface{}{"A", "B"}},
+			want:    `{"models":[{"model_sku":"A"},{"model_sku":"B"}]}`,
+			back:    `{"skus":["A","B"]}`,
+		},
+		{
+			name:    "nested arrays",
+			mapping: &models.FieldMapping{SourceField: "orders.*.items.*.qty", TargetField: "lines.*.units.*.quantity"},
+			input: map[string]interface{}{"orders": []interface{}{
+				map[string]interface{}{"items": []interface{}{map[string]interface{}{"qty": 1}, map[string]interface{}{"qty": 2}}},
+				map[string]interface{}{"items": []interface{}{map[string]interface{}{"qty": 3}}},
+			}},
+			want: `{"lines":[{"units":[{"quantity":1},{"quantity":2}]},{"units":[{"quantity":3}]}]}`,
+			back: `{"orders":[{"items":[{"qty":1},{"qty":2}]},{"items":[{"qty":3}]}]}`,
+		},
+		{
+			name:    "fixed index",
+			mapping: &models.FieldMapping{SourceField: "images.0.url", TargetField: "cover"},
+			input:   map[string]interface{}{"images": []interface{}{map[string]interface{}{"url": "a.jpg"}, map[string]interface{}{"url": "b.jpg"}}},
+			want:    `{"cover":"a.jpg"}`,
+			back:    `{"images":[{"url":"a.jpg"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		m := newTestMapper("product", tt.mapping)
+
+		out, err := m.Transform("test", "", "product", tt.input)
+		if err != nil {
+			t.Fatalf("%s: Transform: %v", tt.name, err)
+		}
+		if got, _ := json.Marshal(out); string(got) != tt.want {
+			t.Errorf("%s: Transform = %s, want %s", tt.name, got, tt.want)
+		}
+
+		back, err := m.ReverseTransform("test", "", "product", out)
+		if err != nil {
+			t.Fatalf("%s: ReverseTransform: %v", tt.name, err)
+		}
+		if got, _ := json.Marshal(back); string(got) != tt.back {
+			t.Errorf("%s: ReverseTransform = %s, want %s", tt.name, got, tt.back)
+		}
+	}
+
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "variants.*.sku", TargetField: "models.*.ids.*"},
+	)
+	if _, err := m.Transform("test", "", "product", map[string]interface{}{"variants": []interface{}{map[string]interface{}{"sku": "A"}}}); err == nil {
+		t.Error("Transform with mismatched wildcards succeeded, want an error")
+	}
+}
+
+func TestConditionalMappings(t *testing.T) {
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "status", TargetField: "order_status"},
+		&models.FieldMapping{SourceField: "currency", TargetField: "currency_code", Transform: "uppercase"},
+		&models.FieldMapping{SourceField: "total", TargetField: "total_usd", Condition: `currency == "usd"`},
+		&models.FieldMapping{SourceField: "total", TargetField: "total_thb", Condition: `currency == "thb"`},
+		&models.FieldMapping{SourceField: "note", TargetField: "buyer_note", Condition: `status != "cancelled" && total > 0`},
+	)
+
+	tests := []struct {
+		name   string
+		mercur map[string]interface{}
+		want   string
+	}{
+		{
+			name:   "usd",
+			mercur: map[string]interface{}{"status": "paid", "currency": "usd", "total": json.Number("10"), "note": "hi"},
+			want:   `{"buyer_note":"hi","currency_code":"USD","order_status":"paid","total_usd":10}`,
+		},
+		{
+			name:   "thb, cancelled",
+			mercur: map[string]interface{}{"status": "cancelled", "currency": "thb", "total": json.Number("350"), "note": "hi"},
+			want:   `{"currency_code":"THB","order_status":"cancelled","total_thb":350}`,
+		},
+		{
+			name:   "no currency",
+			mercur: map[string]interface{}{"status": "paid", "total": json.Number("0"), "note": "hi"},
+			want:   `{"order_status":"paid"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := m.Transform("test", "", "order", tt.mercur)
+		if err != nil {
+			t.Fatalf("%s: Transform: %v", tt.name, err)
+		}
+		if got, _ := json.Marshal(out); string(got) != tt.want {
+			t.Errorf("%s: Transform = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// In reverse, conditions test the rebuilt MercurJS fields (currency,
+	// status), not the platform's (currency_code, order_status)
+	m = newTestMapper("order",
+		&models.FieldMapping{SourceField: "status", TargetField: "order_status"},
+		&models.FieldMapping{SourceField: "currency", TargetField: "currency_code"},
+		&models.FieldMapping{SourceField: "total", TargetField: "total_usd", Condition: `currency == "usd"`},
+		&models.FieldMapping{SourceField: "total", TargetField: "total_thb", Condition: `currency == "thb"`},
+		&models.FieldMapping{SourceField: "note", TargetField: "buyer_note", Condition: `status != "cancelled"`},
+	)
+	reverseTests := []struct {
+		platform map[string]interface{}
+		want     string
+	}{
+		{
+			platform: map[string]interface{}{"order_status": "paid", "currency_code": "usd", "total_usd": json.Number("10"), "total_thb": json.Number("350"), "buyer_note": "hi"},
+			want:     `{"currency":"usd","note":"hi","status":"paid","total":10}`,
+		},
+		{
+			platform: map[string]interface{}{"order_status": "cancelled", "currency_code": "thb", "total_usd": json.Number("10"), "total_thb": json.Number("350"), "buyer_note": "hi"},
+			want:     `{"currency":"thb","status":"cancelled","total":350}`,
+		},
+	}
+	for _, tt := range reverseTests {
+		back, err := m.ReverseTransform("test", "", "order", tt.platform)
+		if err != nil {
+			t.Fatalf("ReverseTransform: %v", err)
+		}
+		if got, _ := json.Marshal(back); string(got) != tt.want {
+			t.Errorf("ReverseTransform(%v) = %s, want %s", tt.platform, got, tt.want)
+		}
+	}
+
+	// Fields the condition tests may also come from the passthrough policy
+	m = newTestMapper("order",
+		&models.FieldMapping{SourceField: "status", TargetField: "order_status", Condition: `type == "marketplace"`},
+	)
+	m.policyCache["test:order"] = &cachedPolicy{policy: &models.MappingPolicy{UnmappedFields: models.UnmappedPassthrough}, fetchedAt: time.Now()}
+	back, err := m.ReverseTransform("test", "", "order", map[string]interface{}{"type": "marketplace", "order_status": "x"})
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got := back["status"]; got != "x" {
+		t.Errorf("status = %v, want x", got)
+	}
+}
+
+func TestLookupTables(t *testing.T) {
+	unknown, unknownBack := "UNKNOWN", "pending"
+	m := newTestMapper("order",
+		&models.FieldMapping{SourceField: "status", TargetField: "order_status", Transform: "lookup(order_status)"},
+		&models.FieldMapping{SourceField: "carrier", TargetField: "logistics", Transform: "lowercase|lookup(carriers)"},
+	)
+	m.lookupCache["order_status"] = &cachedLookup{table: &models.LookupTable{
+		Name:                "order_status",
+		DefaultValue:        &unknown,
+		ReverseDefaultValue: &unknownBack,
+		Entries: []models.LookupEntry{
+			{Source: "pending", Target: "UNPAID"},
+			{Source: "completed", Target: "COMPLETED"},
+			{Source: "delivered", Target: "COMPLETED"},
+		},
+	}, fetchedAt: time.Now()}
+	m.lookupCache["carriers"] = &cachedLookup{table: &models.LookupTable{
+		Name:    "carriers",
+		Entries: []models.LookupEntry{{Source: "dhl", Target: "DHL_EXPRESS"}},
+	}, fetchedAt: time.Now()}
+
+	tests := []struct {
+		status, carrier string
+		wantStatus      string
+		wantCarrier     string
+		backStatus      string
+		backCarrier     string
+	}{
+		{"pending", "DHL", "UNPAID", "DHL_EXPRESS", "pending", "dhl"},
+		{"delivered", "dhl", "COMPLETED", "DHL_EXPRESS", "completed", "dhl"},
+		// No entry: the table default applies, or the value passes through
+		{"refunded", "Kerry", "UNKNOWN", "kerry", "pending", "kerry"},
+	}
+
+	for _, tt := range tests {
+		out, err := m.Transform("test", "", "order", map[string]interface{}{"status": tt.status, "carrier": tt.carrier})
+		if err != nil {
+			t.Fatalf("Transform(%s): %v", tt.status, err)
+		}
+		if out["order_status"] != tt.wantStatus || out["logistics"] != tt.wantCarrier {
+			t.Errorf("Transform(%s, %s) = %v, want %s, %s", tt.status, tt.carrier, out, tt.wantStatus, tt.wantCarrier)
+		}
+
+		back, err := m.ReverseTransform("test", "", "order", out)
+		if err != nil {
+			t.Fatalf("ReverseTransform(%v): %v", out, err)
+		}
+		if back["status"] != tt.backStatus || back["carrier"] != tt.backCarrier {
+			t.Errorf("ReverseTransform(%v) = %v, want %s, %s", out, back, tt.backStatus, tt.backCarrier)
+		}
+	}
+
+	if err := ValidatePipeline("lookup"); err == nil {
+		t.Error("lookup without a table validated")
+	}
+}
+
+func TestDefaultsAndRequiredFields(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "title", TargetField: "item_name", Required: true},
+		&models.FieldMapping{SourceField: "sku", TargetField: "item_sku", Required: true},
+		&models.FieldMapping{SourceField: "status", TargetField: "item_status", DefaultValue: `"NORMAL"`, Required: true},
+		&models.FieldMapping{SourceField: "weight", TargetField: "weight", DefaultValue: "0.5"},
+		&models.FieldMapping{SourceField: "dimensions", TargetField: "dimension", DefaultValue: `{"unit": "cm"}`},
+	)
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		want    string
+		missing []string
+	}{
+		{
+			name:  "all present",
+			input: map[string]interface{}{"title": "Shirt", "sku": "S-1", "status": "UNLIST", "weight": json.Number("2"), "dimensions": map[string]interface{}{"unit": "in"}},
+			want:  `{"dimension":{"unit":"in"},"item_name":"Shirt","item_sku":"S-1","item_status":"UNLIST","weight":2}`,
+		},
+		{
+			name:  "defaults",
+			input: map[string]interface{}{"title": "Shirt", "sku": "S-1"},
+			want:  `{"dimension":{"unit":"cm"},"item_name":"Shirt","item_sku":"S-1","item_status":"NORMAL","weight":0.5}`,
+		},
+		{
+			name:    "required missing",
+			input:   map[string]interface{}{"title": nil},
+			missing: []string{"title", "sku"},
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := m.Transform("test", "", "product", tt.input)
+
+		var validationErr *ValidationError
+		if tt.missing == nil {
+			if err != nil {
+				t.Fatalf("%s: Transform: %v", tt.name, err)
+			}
+			if got, _ := json.Marshal(out); string(got) != tt.want {
+				t.Errorf("%s: Transform = %s, want %s", tt.name, got, tt.want)
+			}
+			continue
+		}
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("%s: Transform error = %v, want a *ValidationError", tt.name, err)
+		}
+		if !reflect.DeepEqual(validationErr.Missing, tt.missing) {
+			t.Errorf("%s: missing = %v, want %v", tt.name, validationErr.Missing, tt.missing)
+		}
+	}
+
+	// ReverseTransform neither fills defaults nor requires fields: a platform
+	// payload is taken as sent
+	back, err := m.ReverseTransform("test", "", "product", map[string]interface{}{"item_sku": "S-1"})
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got, _ := json.Marshal(back); string(got) != `{"sku":"S-1"}` {
+		t.Errorf("ReverseTransform = %s, want only sku", got)
+	}
+
+	m = newTestMapper("product",
+		&models.FieldMapping{SourceField: "weight", TargetField: "weight", DefaultValue: "{oops"},
+	)
+	if _, err := m.Transform("test", "", "product", map[string]interface{}{}); err == nil {
+		t.Error("Transform with an invalid default value succeeded, want an error")
+	}
+}
+
+func TestUnmappedFieldPolicy(t *testing.T) {
+	mappings := []*models.FieldMapping{
+		{SourceField: "title", TargetField: "item_name"},
+		{SourceField: "shipping.weight", TargetField: "weight"},
+		{SourceField: "variants.*.sku", TargetField: "models.*.model_sku"},
+	}
+	input := map[string]interface{}{
+		"title":    "Shirt",
+		"brand":    "Acme",
+		"shipping": map[string]interface{}{"weight": json.Number("1")},
+		"variants": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B", "color": "red"}},
+		"customer": map[string]interface{}{"email": "jane@example.com", "name": "Jane"},
+		"meta":     map[string]interface{}{},
+	}
+
+	tests := []struct {
+		mode   string
+		except []string
+		want   string
+	}{
+		{"", nil, `{"item_name":"Shirt","models":[{"model_sku":"A"},{"model_sku":"B"}],"weight":1}`},
+		{models.UnmappedStrict, nil, `{"item_name":"Shirt","models":[{"model_sku":"A"},{"model_sku":"B"}],"weight":1}`},
+		// Consumed fields leave no empty objects behind, but empty input
+		// objects are kept
+		{models.UnmappedPassthrough, nil, `{"brand":"Acme","customer":{"email":"jane@example.com","name":"Jane"},"item_name":"Shirt","meta":{},"models":[{"model_sku":"A"},{"model_sku":"B"}],"variants":[null,{"color":"red"}],"weight":1}`},
+		{models.UnmappedPassthroughExcept, []string{"customer.email", "customer.name", "variants.*.color"}, `{"brand":"Acme","item_name":"Shirt","meta":{},"models":[{"model_sku":"A"},{"model_sku":"B"}],"weight":1}`},
+	}
+
+	for _, tt := range tests {
+		m := newTestMapper("product", mappings...)
+		if tt.mode != "" {
+			m.policyCache["test:product"] = &cachedPolicy{policy: &models.MappingPolicy{UnmappedFields: tt.mode, ExceptPaths: tt.except}, fetchedAt: time.Now()}
+		}
+
+		out, err := m.Transform("test", "", "product", deepCopy(input).(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("%s: Transform: %v", tt.mode, err)
+		}
+		if got, _ := json.Marshal(out); string(got) != tt.want {
+			t.Errorf("%s: Transform = %s\nwant %s", tt.mode, got, tt.want)
+		}
+	}
+
+	// The policy applies to ReverseTransform too
+	m := newTestMapper("product", mappings...)
+	m.policyCache["test:product"] = &cachedPolicy{policy: &models.MappingPolicy{UnmappedFields: models.UnmappedPassthrough}, fetchedAt: time.Now()}
+	back, err := m.ReverseTransform("test", "", "product", map[string]interface{}{
+		"item_name": "Shirt",
+		"weight":    json.Number("1"),
+		"logistics": map[string]interface{}{"channel": "dhl"},
+	})
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if got, _ := json.Marshal(back); string(got) != `{"logistics":{"channel":"dhl"},"shipping":{"weight":1},"title":"Shirt"}` {
+		t.Errorf("ReverseTransform = %s", got)
+	}
+
+	// Without mappings or a policy the payload passes through unchanged
+	m = newTestMapper("product")
+	out, err := m.Transform("test", "", "product", input)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if !reflect.DeepEqual(out, input) {
+		t.Errorf("Transform without mappings = %v, want the input", out)
+	}
+}
+
+func TestDeletePathPrunesEmptyParents(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"x.y", `{"a":1,"b":{"c":2},"items":[{"sku":"A"},{"sku":"B","qty":1}]}`},
+		{"b.c", `{"a":1,"items":[{"sku":"A"},{"qty":1,"sku":"B"}],"x":{"y":3}}`},
+		{"items.*.sku", `{"a":1,"b":{"c":2},"items":[null,{"qty":1}],"x":{"y":3}}`},
+		{"items.0.sku", `{"a":1,"b":{"c":2},"items":[null,{"qty":1,"sku":"B"}],"x":{"y":3}}`},
+		{"missing.y", `{"a":1,"b":{"c":2},"items":[{"sku":"A"},{"qty":1,"sku":"B"}],"x":{"y":3}}`},
+	}
+
+	for _, tt := range tests {
+		data := map[string]interface{}{
+			"a":     1,
+			"b":     map[string]interface{}{"c": 2},
+			"x":     map[string]interface{}{"y": 3},
+			"items": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B", "qty": 1}},
+		}
+		deletePath(data, tt.path)
+
+		var want map[string]interface{}
+		_ = json.Unmarshal([]byte(tt.want), &want)
+		got, _ := json.Marshal(data)
+		wantJSON, _ := json.Marshal(want)
+		if string(got) != string(wantJSON) {
+			t.Errorf("deletePath(%s) = %s, want %s", tt.path, got, wantJSON)
+		}
+	}
+
+	data := map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B"}}}
+	deletePath(data, "items.*.sku")
+	if len(data) != 0 {
+		t.Errorf("deletePath(items.*.sku) = %v, want the emptied array removed", data)
+	}
+}
+
+func TestTemplateAndSplitRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *models.FieldMapping
+		mercur  map[string]interface{}
+		want    string
+		// back is the ReverseTransform of want
+		back string
+	}{
+		{
+			name:    "template",
+			mapping: &models.FieldMapping{SourceField: "{shipping.first_name} {shipping.last_name}", TargetField: "recipient"},
+			mercur:  map[string]interface{}{"shipping": map[string]interface{}{"first_name": "Jane", "last_name": "Doe"}},
+			want:    `{"recipient":"Jane Doe"}`,
+			back:    `{"shipping":{"first_name":"Jane","last_name":"Doe"}}`,
+		},
+		{
+			name:    "template with transform",
+			mapping: &models.FieldMapping{SourceField: "{handle}-{variants.0.sku}", TargetField: "item_sku", Transform: "uppercase"},
+			mercur:  map[string]interface{}{"handle": "shirt", "variants": []interface{}{map[string]interface{}{"sku": "xl"}}},
+			want:    `{"item_sku":"SHIRT-XL"}`,
+			back:    `{"handle":"SHIRT","variants":[{"sku":"XL"}]}`,
+		},
+		{
+			name:    "template with a missing placeholder",
+			mapping: &models.FieldMapping{SourceField: "{first_name} {last_name}", TargetField: "recipient"},
+			mercur:  map[string]interface{}{"first_name": "Jane"},
+			want:    `{}`,
+			back:    `{}`,
+		},
+		{
+			name:    "literal split",
+			mapping: &models.FieldMapping{SourceField: "phone", TargetField: "country_code,number", SplitPattern: "-"},
+			mercur:  map[string]interface{}{"phone": "66-812345678"},
+			want:    `{"country_code":"66","number":"812345678"}`,
+			back:    `{"phone":"66-812345678"}`,
+		},
+		{
+			name:    "regex split is one-way",
+			mapping: &models.FieldMapping{SourceField: "code", TargetField: "prefix,number", SplitPattern: `/^([A-Z]+)(\d+)$/`},
+			mercur:  map[string]interface{}{"code": "SKU123"},
+			want:    `{"number":"123","prefix":"SKU"}`,
+			back:    `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		m := newTestMapper("order", tt.mapping)
+
+		out, err := m.Transform("test", "", "order", tt.mercur)
+		if err != nil {
+			t.Fatalf("%s: Transform: %v", tt.name, err)
+		}
+		if got, _ := json.Marshal(out); string(got) != tt.want {
+			t.Errorf("%s: Transform = %s, want %s", tt.name, got, tt.want)
+		}
+
+		back, err := m.ReverseTransform("test", "", "order", out)
+		if err != nil {
+			t.Fatalf("%s: ReverseTransform: %v", tt.name, err)
+		}
+		if got, _ := json.Marshal(back); string(got) != tt.back {
+			t.Errorf("%s: ReverseTransform = %s, want %s", tt.name, got, tt.back)
+		}
+	}
+
+	for _, mapping := range []*models.FieldMapping{
+		{SourceField: "{first_name}{last_name}", TargetField: "recipient"},
+		{SourceField: "{items.*.sku}", TargetField: "skus"},
+		{SourceField: "{first_name", TargetField: "recipient"},
+	} {
+		m := newTestMapper("order", mapping)
+		if _, err := m.Transform("test", "", "order", map[string]interface{}{}); err == nil {
+			t.Errorf("template %q compiled, want an error", mapping.SourceField)
+		}
+	}
+}
+
+// doubleTransform is a custom transform without a description
+type doubleTransform struct{}
+
+func (doubleTransform) Forward(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	return toInt(value) * 2, nil
+}
+
+func (doubleTransform) Inverse(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	return toInt(value) / 2, nil
+}
+
+func (doubleTransform) Validate(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("takes no arguments")
+	}
+	return nil
+}
+
+func TestTransformRegistry(t *testing.T) {
+	// The registry is global, so a second run (-count) finds it registered
+	if _, ok := GetTransform("test_double"); !ok {
+		Register("test_double", doubleTransform{})
+	}
+	if impl, ok := GetTransform("test_double"); !ok || impl != (doubleTransform{}) {
+		t.Fatal("test_double is not registered")
+	}
+
+	descriptors := Transforms()
+	byName := make(map[string]Descriptor, len(descriptors))
+	for i, d := range descriptors {
+		if i > 0 && descriptors[i-1].Name >= d.Name {
+			t.Errorf("Transforms() not sorted: %s before %s", descriptors[i-1].Name, d.Name)
+		}
+		byName[d.Name] = d
+	}
+	tests := []struct {
+		name      string
+		signature string
+		described bool
+	}{
+		{"test_double", "test_double", false},
+		{"pad_left", "pad_left(width, pad?)", true},
+		{"lookup", "lookup(table)", true},
+		{"date", "date(in, out, tz?)", true},
+	}
+	for _, tt := range tests {
+		d, ok := byName[tt.name]
+		if !ok {
+			t.Errorf("Transforms() has no %s", tt.name)
+			continue
+		}
+		if d.Signature != tt.signature || (d.Description != "") != tt.described {
+			t.Errorf("%s descriptor = %+v", tt.name, d)
+		}
+	}
+
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "stock", TargetField: "stock", Transform: "int|test_double"},
+	)
+	out, err := m.Transform("test", "", "product", map[string]interface{}{"stock": "21"})
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if out["stock"] != 42 {
+		t.Errorf("stock = %v, want 42", out["stock"])
+	}
+	back, err := m.ReverseTransform("test", "", "product", out)
+	if err != nil {
+		t.Fatalf("ReverseTransform: %v", err)
+	}
+	if back["stock"] != 21 {
+		t.Errorf("reverse stock = %v, want 21", back["stock"])
+	}
+
+	if err := ValidatePipeline("test_double(2)"); err == nil {
+		t.Error("test_double(2) validated, want its own argument check")
+	}
+
+	for _, name := range []string{"test_double", "not valid", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(name, doubleTransform{})
+		}()
+	}
+}
+
+func TestConvertCurrency(t *testing.T) {
+	m := newTestMapper("product",
+		&models.FieldMapping{SourceField: "currency_code", TargetField: "currency"},
+		&models.FieldMapping{SourceField: "price", TargetField: "price", Transform: "convert_currency(currency_code, USD)"},
+		&models.FieldMapping{SourceField: "variants.*.price", TargetField: "models.*.price", Transform: "convert_currency(currency_code, USD, 3, down)"},
+	)
+	m.rateCache["THB:USD"] = cachedRate{rate: &models.ExchangeRate{BaseCurrency: "THB", QuoteCurrency: "USD", Rate: "0.0275"}, fetchedAt: time.Now()}
+
+	out, err := m.Transform("test", "", "product", map[string]interface{}{
+		"currency_code": "thb",
+		"price":         json.Number("333"),
+		"variants":      []interface{}{map[string]interface{}{"price": json.Number("100")}, map[string]interface{}{"price": json.Number("99.99")}},
+	})
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	want := map[string]interface{}{
+		"price":                 json.Number("9.16"),
+		"models.0.price":        json.Number("2.75"),
+		"models.1.price":        json.Number("2.749"),
+		"_exchange_rates.price": map[string]interface{}{"from": "THB", "to": "USD", "rate": json.Number("0.0275")},
+	}
+	for path, value := range want {
+		if got := getNestedValue(out, path); !reflect.DeepEqual(got, value) {
+			t.Errorf("%s = %#v, want %#v", path, got, value)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		platform map[string]interface{}
+		want     interface{}
+	}{
+		// The adapter's own payload converts back at the recorded rate,
+		// which loses the rounding
+		{"recorded rate", out, json.Number("333.09")},
+		// Platform payloads have no record: the rate in effect is used
+		{"rate in effect", map[string]interface{}{"currency": "THB", "price": json.Number("9.16")}, json.Number("333.09")},
+		{"another amount", map[string]interface{}{"currency": "THB", "price": json.Number("11")}, json.Number("400")},
+	}
+	for _, tt := range tests {
+		back, err := m.ReverseTransform("test", "", "product", tt.platform)
+		if err != nil {
+			t.Fatalf("%s: ReverseTransform: %v", tt.name, err)
+		}
+		if got := back["price"]; got != tt.want {
+			t.Errorf("%s: price = %#v, want %#v", tt.name, got, tt.want)
+		}
+		if _, ok := back[exchangeRatesSection]; ok {
+			t.Errorf("%s: %s was passed through", tt.name, exchangeRatesSection)
+		}
+	}
+
+	if _, err := m.ReverseTransform("test", "", "product", map[string]interface{}{"price": json.Number("9.16")}); err == nil {
+		t.Error("ReverseTransform without a currency succeeded, want an error")
+	}
+
+	issues := LintMappings([]*models.FieldMapping{
+		{SourceField: "price", TargetField: "price", Transform: "convert_currency(currency_code, USD)", IsActive: true},
+	})
+	if len(issues) != 1 || issues[0].Code != LintNotInvertible || issues[0].Severity != LintWarning {
+		t.Errorf("lint issues = %+v, want a not_invertible warning", issues)
+	}
+}
@@ -0,0 +1,107 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mappingScope is what a mapping set is applied for: a platform, optionally a
+// shop, and the entity type. Nested mappings add the child entity type after
+// the ones being mapped, so entities holds the chain outermost first.
+type mappingScope struct {
+	platformID string
+	shopID     string
+	entities   []string
+}
+
+func newScope(platformID, shopID, entityType string) mappingScope {
+	return mappingScope{platformID: platformID, shopID: shopID, entities: []string{entityType}}
+}
+
+func (s mappingScope) entityType() string {
+	return s.entities[len(s.entities)-1]
+}
+
+// nest returns the scope of a child entity type, or an error if it is already
+// on the chain
+func (s mappingScope) nest(entityType string) (mappingScope, error) {
+	for _, seen := range s.entities {
+		if seen == entityType {
+			return mappingScope{}, fmt.Errorf("nested mapping cycle: %s -> %s", strings.Join(s.entities, " -> "), entityType)
+		}
+	}
+
+	entities := make([]string, len(s.entities), len(s.entities)+1)
+	copy(entities, s.entities)
+	s.entities = append(entities, entityType)
+	return s, nil
+}
+
+func init() {
+	Register("nested", &builtin{
+		signature:   "nested(entity_type)",
+		description: "Maps an object, or each object of an array, with the mappings of entity_type on the same platform and shop, e.g. variants with the variant mappings",
+		minArgs:     1,
+		maxArgs:     1,
+		forward:     mapNested,
+		list:        true,
+		validate: func(args []string) error {
+			if !isIdentifier(args[0]) {
+				return fmt.Errorf("invalid entity type %q", args[0])
+			}
+			return nil
+		},
+	})
+}
+
+// mapNested maps an object, or each object of an array, as the entity type in
+// args[0]. Other values are passed through. The same function is the inverse:
+// the context carries the direction.
+func mapNested(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	entityType := strings.ToLower(args[0])
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out, err := ctx.MapEntity(entityType, v)
+		if err != nil {
+			return nil, qualifyMissing(err, ctx.path)
+		}
+		return out, nil
+	case []interface{}:
+		mapped := make([]interface{}, len(v))
+		for i, elem := range v {
+			obj, ok := elem.(map[string]interface{})
+			if !ok {
+				mapped[i] = elem
+				continue
+			}
+			out, err := ctx.MapEntity(entityType, obj)
+			if err != nil {
+				if ctx.path != "" {
+					err = qualifyMissing(err, ctx.path+"."+strconv.Itoa(i))
+				}
+				return nil, fmt.Errorf("%s %d: %w", entityType, i, err)
+			}
+			mapped[i] = out
+		}
+		return mapped, nil
+	}
+	return value, nil
+}
+
+// qualifyMissing prefixes the missing fields of a nested entity's
+// ValidationError with the path of the entity, so they read as paths of the
+// outer document, e.g. variants.1.sku
+func qualifyMissing(err error, path string) error {
+	var validationErr *ValidationError
+	if path == "" || !errors.As(err, &validationErr) {
+		return err
+	}
+	// Each run returns a new ValidationError, so it is updated in place
+	for i, field := range validationErr.Missing {
+		validationErr.Missing[i] = path + "." + field
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package mapper
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// applyUnmappedPolicy combines the mapped result with the fields of the input
+// document that no mapping consumed, according to the platform/entity policy.
+// Without a policy (or in strict mode) only the mapped result is returned.
+func applyUnmappedPolicy(policy *models.MappingPolicy, data, mapped map[string]interface{}, consumed []string) map[string]interface{} {
+	if policy == nil || policy.UnmappedFields == models.UnmappedStrict {
+		return mapped
+	}
+
+	base, _ := deepCopy(data).(map[string]interface{})
+	if base == nil {
+		base = make(map[string]interface{})
+	}
+
+	for _, path := range consumed {
+		deletePath(base, path)
+	}
+	if policy.UnmappedFields == models.UnmappedPassthroughExcept {
+		for _, path := range policy.ExceptPaths {
+			deletePath(base, path)
+		}
+	}
+
+	mergeValues(base, mapped)
+	return base
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			out[k] = deepCopy(elem)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, elem := range val {
+			out[i] = deepCopy(elem)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// deletePath removes the value at a dot path, walking every element for `*`
+// segments. Array elements addressed by index are set to nil so that the
+// remaining elements keep their positions. Objects and arrays left empty by
+// the removal are removed too, so consumed fields leave no `{}` behind.
+func deletePath(data map[string]interface{}, path string) {
+	deleteNode(data, strings.Split(path, "."))
+}
+
+// deleteNode removes the value at parts under current and reports whether
+// current was left empty by it: an object without keys or an array of nils
+func deleteNode(current interface{}, parts []string) bool {
+	last := len(parts) == 1
+	part := parts[0]
+
+	switch v := current.(type) {
+	case map[string]interface{}:
+		next, ok := v[part]
+		if !ok {
+			return false
+		}
+		if last || deleteNode(next, parts[1:]) {
+			delete(v, part)
+			return len(v) == 0
+		}
+	case []interface{}:
+		removed := false
+		if part == "*" {
+			for i := range v {
+				if v[i] != nil && (last || deleteNode(v[i], parts[1:])) {
+					v[i] = nil
+					removed = true
+				}
+			}
+		} else {
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return false
+			}
+			if v[idx] != nil && (last || deleteNode(v[idx], parts[1:])) {
+				v[idx] = nil
+				removed = true
+			}
+		}
+		return removed && allNil(v)
+	}
+	return false
+}
+
+func allNil(values []interface{}) bool {
+	for _, value := range values {
+		if value != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeValues deep-merges src into dst. Objects are merged key by key and
+// arrays element by element; any other src value replaces the dst value.
+func mergeValues(dst, src map[string]interface{}) {
+	for k, value := range src {
+		dst[k] = mergeValue(dst[k], value)
+	}
+}
+
+func mergeValue(dst, src interface{}) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		if d, ok := dst.(map[string]interface{}); ok {
+			mergeValues(d, s)
+			return d
+		}
+	case []interface{}:
+		if d, ok := dst.([]interface{}); ok {
+			for i, elem := range s {
+				if i < len(d) {
+					if elem != nil {
+						d[i] = mergeValue(d[i], elem)
+					}
+				} else {
+					d = append(d, elem)
+				}
+			}
+			return d
+		}
+	}
+	return src
+}
@@ -0,0 +1,313 @@
+package mapper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// Redacted replaces the values of the redact transform
+const Redacted = "[REDACTED]"
+
+// hashSalts are the secrets hash_sha256 keys its hashes with, by name. They
+// come from the configuration, never from the mappings, so the mappings can
+// be exported and shared.
+var (
+	hashSalts   map[string]string
+	hashSaltsMu sync.RWMutex
+)
+
+// SetHashSalts sets the salts hash_sha256(salt_ref) can refer to
+func SetHashSalts(salts map[string]string) {
+	copied := make(map[string]string, len(salts))
+	for name, salt := range salts {
+		copied[name] = salt
+	}
+
+	hashSaltsMu.Lock()
+	hashSalts = copied
+	hashSaltsMu.Unlock()
+}
+
+func hashSalt(name string) (string, bool) {
+	hashSaltsMu.RLock()
+	defer hashSaltsMu.RUnlock()
+	salt, ok := hashSalts[name]
+	return salt, ok
+}
+
+// PII transforms keep customer data from platforms that must not receive it.
+// They cannot be reversed: ReverseTransform passes their output through.
+func init() {
+	Register("mask_email", &builtin{
+		signature:   "mask_email",
+		description: `Hides an email address but its first character and domain, e.g. "jane.doe@example.com" -> "j***@example.com" (lossy)`,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if s, ok := value.(string); ok {
+				return maskEmail(s)
+			}
+			return value
+		}),
+		inverse: identity,
+		lossy:   alwaysLossy,
+	})
+
+	Register("mask_phone", &builtin{
+		signature:   "mask_phone(visible?)",
+		description: `Hides the digits of a phone number but the last visible ones (default 4), keeping its formatting, e.g. "+1 555-123-4567" -> "+* ***-***-4567" (lossy)`,
+		maxArgs:     1,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if value == nil {
+				return nil
+			}
+			visible := 4
+			if len(args) > 0 {
+				visible, _ = strconv.Atoi(args[0])
+			}
+			return maskPhone(toString(value), visible)
+		}),
+		inverse: identity,
+		validate: func(args []string) error {
+			if len(args) > 0 {
+				if n, err := strconv.Atoi(args[0]); err != nil || n < 0 {
+					return fmt.Errorf("visible must be a non-negative integer")
+				}
+			}
+			return nil
+		},
+		lossy: alwaysLossy,
+	})
+
+	Register("hash_sha256", &builtin{
+		signature:   "hash_sha256(salt_ref)",
+		description: "Replaces a value with its hex HMAC-SHA256 keyed with the salt named salt_ref in PII_SALTS, so equal values can still be matched (lossy)",
+		minArgs:     1,
+		maxArgs:     1,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			if value == nil {
+				return nil, nil
+			}
+			salt, ok := hashSalt(args[0])
+			if !ok {
+				return nil, fmt.Errorf("unknown salt %q", args[0])
+			}
+			return hashValue(value, salt), nil
+		},
+		inverse: identity,
+		validate: func(args []string) error {
+			if _, ok := hashSalt(args[0]); !ok {
+				return fmt.Errorf("unknown salt %q (configure it in PII_SALTS)", args[0])
+			}
+			return nil
+		},
+		lossy: alwaysLossy,
+	})
+
+	Register("redact", &builtin{
+		signature:   "redact",
+		description: `Replaces a value, including a whole object or array, with "` + Redacted + `" (lossy)`,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if value == nil {
+				return nil
+			}
+			return Redacted
+		}),
+		inverse: identity,
+		lossy:   alwaysLossy,
+		list:    true,
+	})
+}
+
+// maskEmail keeps the first character of the local part and the domain. The
+// mask has a fixed length, so it does not tell how long the address was.
+func maskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return "***"
+	}
+	first := []rune(s[:at])[0]
+	return string(first) + "***" + s[at:]
+}
+
+// maskPhone replaces every digit but the last visible ones with `*`. A number
+// with no more digits than that is masked entirely.
+func maskPhone(s string, visible int) string {
+	digits := 0
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	if digits <= visible {
+		visible = 0
+	}
+
+	runes := []rune(s)
+	seen := 0
+	for i, r := range runes {
+		if !unicode.IsDigit(r) {
+			continue
+		}
+		seen++
+		if seen <= digits-visible {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
+
+// hashValue returns the HMAC of the string form of a scalar, or of the JSON of
+// an object or array
+func hashValue(value interface{}, salt string) string {
+	var data []byte
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, _ = json.Marshal(value)
+	default:
+		data = []byte(toString(value))
+	}
+
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// compiledPIIRule is a rule of a platform's PII policy
+type compiledPIIRule struct {
+	rule  models.PIIRule
+	path  fieldPath
+	steps []step
+	err   error
+}
+
+// ValidatePIIPolicy checks the rules of a PII policy before it is saved: each
+// path is valid and used once, and each transform pipeline is valid
+func ValidatePIIPolicy(rules []models.PIIRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := validatePath(rule.Path); err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+		if seen[rule.Path] {
+			return fmt.Errorf("path %s is listed twice", rule.Path)
+		}
+		seen[rule.Path] = true
+
+		if strings.TrimSpace(rule.Transform) == "" {
+			return fmt.Errorf("%s: transform is required", rule.Path)
+		}
+		if err := ValidatePipeline(rule.Transform); err != nil {
+			return fmt.Errorf("%s: transform: %w", rule.Path, err)
+		}
+	}
+	return nil
+}
+
+func compilePIIRules(rules []models.PIIRule) []*compiledPIIRule {
+	compiled := make([]*compiledPIIRule, len(rules))
+	for i, rule := range rules {
+		c := &compiledPIIRule{rule: rule, path: compilePath(rule.Path)}
+		c.steps, c.err = parsePipeline(rule.Transform)
+		compiled[i] = c
+	}
+	return compiled
+}
+
+// MaskPII applies the PII policy of a platform to a copy of data. It is what
+// Transform does to its output, for payloads sent without mapping, e.g. when
+// mapping failed.
+func (m *Mapper) MaskPII(platformID string, data map[string]interface{}) (map[string]interface{}, error) {
+	return m.applyPIIPolicy(platformID, data)
+}
+
+// applyPIIPolicy returns data with the platform's PII rules applied, as a
+// copy, or data itself when the platform has no rules
+func (m *Mapper) applyPIIPolicy(platformID string, data map[string]interface{}) (map[string]interface{}, error) {
+	rules, err := m.getPIIRules(platformID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PII policy: %w", err)
+	}
+	if len(rules) == 0 {
+		return data, nil
+	}
+
+	masked := deepCopy(data).(map[string]interface{})
+	ctx := &Context{mapper: m, scope: newScope(platformID, "", ""), doc: data}
+	for _, rule := range rules {
+		applyPIIRule(ctx, rule, masked)
+	}
+	return masked, nil
+}
+
+// applyPIIRule transforms the values at the rule's path. A value the rule
+// fails on is removed rather than sent as is: its key is deleted, or its
+// element set to nil in an array.
+func applyPIIRule(ctx *Context, rule *compiledPIIRule, data map[string]interface{}) {
+	type match struct {
+		indexes []int
+		value   interface{}
+	}
+	var matches []match
+	_ = rule.path.each(data, func(indexes []int, value interface{}) error {
+		matches = append(matches, match{indexes: append([]int(nil), indexes...), value: value})
+		return nil
+	})
+
+	for _, mt := range matches {
+		err := rule.err
+		var out interface{}
+		if err == nil {
+			out, err = applySteps(ctx, mt.value, rule.steps)
+		}
+		if err != nil {
+			log.Printf("[mapper] PII rule %s (%s) failed, removing the value: %v", rule.rule.Path, rule.rule.Transform, err)
+			deletePath(data, rule.path.resolve(mt.indexes))
+			continue
+		}
+		rule.path.set(data, mt.indexes, out)
+	}
+}
+
+// getPIIRules returns the compiled PII rules of a platform
+func (m *Mapper) getPIIRules(platformID string) ([]*compiledPIIRule, error) {
+	m.mu.RLock()
+	generation := m.generation
+	if cached, ok := m.piiCache[platformID]; ok && m.fresh(cached.fetchedAt) {
+		m.mu.RUnlock()
+		return cached.rules, nil
+	}
+	m.mu.RUnlock()
+
+	if m.piiRepo == nil {
+		return nil, nil
+	}
+
+	fetchedAt := time.Now()
+	policy, err := m.piiRepo.Find(platformID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*compiledPIIRule
+	if policy != nil {
+		rules = compilePIIRules(policy.Rules)
+	}
+
+	m.mu.Lock()
+	if m.generation == generation {
+		m.piiCache[platformID] = &cachedPIIPolicy{rules: rules, fetchedAt: fetchedAt}
+	}
+	m.mu.Unlock()
+
+	return rules, nil
+}
@@ -0,0 +1,238 @@
+package mapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is a single transform call inside a pipeline, e.g. pad_left(8,"0")
+type step struct {
+	name string
+	args []string
+	impl Transform
+	// compiled is the result of the transform's Compile, if it has one
+	compiled interface{}
+}
+
+// parsePipeline parses a transform expression such as `trim|lowercase|string`
+// or `round(2)|pad_left(8,"0")` into its steps, resolving each one in the
+// transform registry and validating its arguments
+func parsePipeline(expr string) ([]step, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	var steps []step
+	for _, raw := range splitTopLevel(expr, '|') {
+		s, err := parseStep(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, err
+		}
+		impl, ok := GetTransform(s.name)
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", s.name)
+		}
+		if err := impl.Validate(s.args); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		if compiler, ok := impl.(Compiler); ok {
+			if s.compiled, err = compiler.Compile(s.args); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.name, err)
+			}
+		}
+		s.impl = impl
+		steps = append(steps, s)
+	}
+
+	return steps, nil
+}
+
+// ValidatePipeline checks that a transform expression is well formed and that
+// every step names a registered transform with valid arguments
+func ValidatePipeline(expr string) error {
+	_, err := parsePipeline(expr)
+	return err
+}
+
+// applySteps applies each step of a transform pipeline in order
+func applySteps(ctx *Context, value interface{}, steps []step) (interface{}, error) {
+	vt := ValueTrace{Read: value}
+	for _, s := range steps {
+		var err error
+		ctx.compiled = s.compiled
+		if value, err = s.impl.Forward(ctx, value, s.args); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		if ctx.trace != nil {
+			vt.Steps = append(vt.Steps, StepTrace{Transform: s.String(), Output: value})
+		}
+	}
+	vt.Written = value
+	ctx.traceValue(vt)
+	return value, nil
+}
+
+// applyInverseSteps undoes a transform pipeline by applying the inverse of
+// each step in reverse order
+func applyInverseSteps(ctx *Context, value interface{}, steps []step) (interface{}, error) {
+	vt := ValueTrace{Read: value}
+	for i := len(steps) - 1; i >= 0; i-- {
+		var err error
+		ctx.compiled = steps[i].compiled
+		if value, err = steps[i].impl.Inverse(ctx, value, steps[i].args); err != nil {
+			return nil, fmt.Errorf("%s: %w", steps[i].name, err)
+		}
+		if ctx.trace != nil {
+			vt.Steps = append(vt.Steps, StepTrace{Transform: "inverse " + steps[i].String(), Output: value})
+		}
+	}
+	vt.Written = value
+	ctx.traceValue(vt)
+	return value, nil
+}
+
+// String formats the step the way it is written in a pipeline
+func (s step) String() string {
+	if len(s.args) == 0 {
+		return s.name
+	}
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		if isIdentifier(arg) {
+			args[i] = arg
+		} else {
+			args[i] = strconv.Quote(arg)
+		}
+	}
+	return s.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func parseStep(raw string) (step, error) {
+	if raw == "" {
+		return step{}, fmt.Errorf("empty transform in pipeline")
+	}
+
+	open := strings.IndexByte(raw, '(')
+	if open == -1 {
+		if !isIdentifier(raw) {
+			return step{}, fmt.Errorf("invalid transform name %q", raw)
+		}
+		return step{name: raw}, nil
+	}
+
+	name := strings.TrimSpace(raw[:open])
+	if !isIdentifier(name) {
+		return step{}, fmt.Errorf("invalid transform name %q", name)
+	}
+	if !strings.HasSuffix(raw, ")") {
+		return step{}, fmt.Errorf("%s: missing closing parenthesis", name)
+	}
+
+	body := strings.TrimSpace(raw[open+1 : len(raw)-1])
+	if body == "" {
+		return step{name: name}, nil
+	}
+
+	var args []string
+	for _, rawArg := range splitTopLevel(body, ',') {
+		arg, err := parseArg(strings.TrimSpace(rawArg))
+		if err != nil {
+			return step{}, fmt.Errorf("%s: %w", name, err)
+		}
+		args = append(args, arg)
+	}
+
+	return step{name: name, args: args}, nil
+}
+
+func parseArg(raw string) (string, error) {
+	if raw == "" {
+		return "", fmt.Errorf("empty argument")
+	}
+
+	// A quoted string is unquoted. Other arguments are kept as written, so
+	// expressions such as status == "active" need no escaping.
+	if strings.HasPrefix(raw, `"`) {
+		if unquoted, err := strconv.Unquote(raw); err == nil {
+			return unquoted, nil
+		}
+	}
+
+	if !balanced(raw) {
+		return "", fmt.Errorf("invalid argument %s", raw)
+	}
+
+	return raw, nil
+}
+
+// balanced reports whether the quotes and parentheses of s are closed
+func balanced(s string) bool {
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case c == '"':
+			inQuote = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && !inQuote
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside quotes or parentheses
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	inQuote := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case c == '"':
+			inQuote = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
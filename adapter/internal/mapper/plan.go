@@ -0,0 +1,240 @@
+package mapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// mappingPlan is a mapping set compiled for Transform and ReverseTransform:
+// paths are split, conditions parsed and transforms resolved once, when the
+// mappings are loaded, instead of on every call
+type mappingPlan struct {
+	mappings []*compiledMapping
+	// conditional is set when a mapping has a condition
+	conditional bool
+}
+
+// compiledMapping is one mapping of a plan
+type compiledMapping struct {
+	mapping *models.FieldMapping
+
+	// condition is nil when the mapping always applies
+	condition condition
+	steps     []step
+	source    fieldPath
+	target    fieldPath
+	splitter  *fieldSplitter
+	template  *fieldTemplate
+
+	// defaultValue is the decoded DefaultValue, written when the source is
+	// missing and hasDefault is set
+	defaultValue interface{}
+	hasDefault   bool
+
+	// Compile errors are kept and returned when the mapping is applied, in the
+	// order the uncompiled mapper ran into them, so one invalid mapping does
+	// not stop the others in a preview
+	conditionErr error
+	err          error
+	defaultErr   error
+}
+
+// compilePlan compiles mappings in order
+func compilePlan(mappings []*models.FieldMapping) *mappingPlan {
+	plan := &mappingPlan{mappings: make([]*compiledMapping, len(mappings))}
+	for i, mapping := range mappings {
+		plan.mappings[i] = compileMapping(mapping)
+		if strings.TrimSpace(mapping.Condition) != "" {
+			plan.conditional = true
+		}
+	}
+	return plan
+}
+
+func compileMapping(mapping *models.FieldMapping) *compiledMapping {
+	c := &compiledMapping{
+		mapping: mapping,
+		source:  compilePath(mapping.SourceField),
+		target:  compilePath(mapping.TargetField),
+	}
+
+	if strings.TrimSpace(mapping.Condition) != "" {
+		c.condition, c.conditionErr = parseCondition(mapping.Condition)
+	}
+
+	c.steps, c.err = parsePipeline(mapping.Transform)
+	if c.err == nil {
+		switch {
+		case mapping.SplitPattern != "":
+			c.splitter, c.err = parseSplit(mapping.SplitPattern, mapping.TargetField)
+		case isTemplate(mapping.SourceField):
+			c.template, c.err = parseTemplate(mapping.SourceField)
+		}
+	}
+
+	if mapping.DefaultValue != "" && c.target.wildcards == 0 {
+		c.hasDefault = true
+		if err := DecodeJSON([]byte(mapping.DefaultValue), &c.defaultValue); err != nil {
+			c.defaultErr = fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	return c
+}
+
+// fieldPath is a dot path split into segments
+type fieldPath struct {
+	raw       string
+	segments  []pathSegment
+	wildcards int
+}
+
+type pathSegment struct {
+	key string
+	// index is the array index of a numeric segment, or -1
+	index    int
+	wildcard bool
+}
+
+func compilePath(path string) fieldPath {
+	parts := strings.Split(path, ".")
+	p := fieldPath{raw: path, segments: make([]pathSegment, len(parts))}
+	for i, part := range parts {
+		seg := pathSegment{key: part, index: -1}
+		if part == "*" {
+			seg.wildcard = true
+			p.wildcards++
+		} else if idx, err := strconv.Atoi(part); err == nil {
+			seg.index = idx
+		}
+		p.segments[i] = seg
+	}
+	return p
+}
+
+func (p fieldPath) String() string {
+	return p.raw
+}
+
+// resolve returns the path with its wildcards replaced by indexes, in order
+func (p fieldPath) resolve(indexes []int) string {
+	if p.wildcards == 0 {
+		return p.raw
+	}
+	parts := make([]string, len(p.segments))
+	for i, seg := range p.segments {
+		parts[i] = seg.key
+		if seg.wildcard && len(indexes) > 0 {
+			parts[i], indexes = strconv.Itoa(indexes[0]), indexes[1:]
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// get returns the value at the path, or nil. Wildcards match nothing.
+func (p fieldPath) get(data map[string]interface{}) interface{} {
+	var current interface{} = data
+
+	for _, seg := range p.segments {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[seg.key]
+		case []interface{}:
+			if seg.index < 0 || seg.index >= len(v) {
+				return nil
+			}
+			current = v[seg.index]
+		default:
+			return nil
+		}
+
+		if current == nil {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// each calls fn with every non-nil value matching the path and the array
+// indexes its wildcards resolved to. The indexes slice is reused between
+// calls. It stops at the first error.
+func (p fieldPath) each(data map[string]interface{}, fn func(indexes []int, value interface{}) error) error {
+	indexes := make([]int, 0, p.wildcards)
+	return eachNode(data, p.segments, indexes, fn)
+}
+
+func eachNode(current interface{}, segments []pathSegment, indexes []int, fn func([]int, interface{}) error) error {
+	if current == nil {
+		return nil
+	}
+	if len(segments) == 0 {
+		return fn(indexes, current)
+	}
+
+	seg := segments[0]
+	switch v := current.(type) {
+	case map[string]interface{}:
+		return eachNode(v[seg.key], segments[1:], indexes, fn)
+	case []interface{}:
+		if seg.wildcard {
+			for i, elem := range v {
+				if err := eachNode(elem, segments[1:], append(indexes, i), fn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if seg.index < 0 || seg.index >= len(v) {
+			return nil
+		}
+		return eachNode(v[seg.index], segments[1:], indexes, fn)
+	}
+	return nil
+}
+
+// set writes value at the path, creating objects and arrays on the way.
+// Wildcard segments take their index from indexes, in order. The first
+// segment is always an object key, even if numeric, since the root is an
+// object.
+func (p fieldPath) set(data map[string]interface{}, indexes []int, value interface{}) {
+	root := p.segments[0].key
+	data[root] = setNode(data[root], p.segments[1:], indexes, value)
+}
+
+func setNode(current interface{}, segments []pathSegment, indexes []int, value interface{}) interface{} {
+	if len(segments) == 0 {
+		return value
+	}
+
+	seg := segments[0]
+	idx := seg.index
+	if seg.wildcard && len(indexes) > 0 {
+		idx, indexes = indexes[0], indexes[1:]
+	}
+
+	if idx >= 0 {
+		arr, _ := current.([]interface{})
+		if len(arr) <= idx {
+			if cap(arr) > idx {
+				arr = arr[:idx+1]
+			} else {
+				grown := make([]interface{}, idx+1, 2*idx+2)
+				copy(grown, arr)
+				arr = grown
+			}
+		}
+		arr[idx] = setNode(arr[idx], segments[1:], indexes, value)
+		return arr
+	}
+
+	obj, ok := current.(map[string]interface{})
+	if !ok || obj == nil {
+		obj = make(map[string]interface{})
+	}
+	obj[seg.key] = setNode(obj[seg.key], segments[1:], indexes, value)
+	return obj
+}
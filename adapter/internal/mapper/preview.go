@@ -0,0 +1,184 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// Outcomes of a mapping in a MappingTrace
+const (
+	StatusMapped          = "mapped"
+	StatusSkipped         = "skipped"          // condition did not match
+	StatusMissing         = "missing"          // nothing to read
+	StatusDefault         = "default"          // nothing to read, default_value written
+	StatusMissingRequired = "missing_required" // nothing to read for a required mapping
+	StatusError           = "error"
+)
+
+// MappingTrace records what one mapping did during a preview
+type MappingTrace struct {
+	PlatformID  string       `json:"platform_id"`
+	ShopID      string       `json:"shop_id,omitempty"`
+	SourceField string       `json:"source_field"`
+	TargetField string       `json:"target_field"`
+	Condition   string       `json:"condition,omitempty"`
+	Transform   string       `json:"transform,omitempty"`
+	Status      string       `json:"status"`
+	Values      []ValueTrace `json:"values,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// ValueTrace follows one value through a transform pipeline
+type ValueTrace struct {
+	Read    interface{} `json:"read,omitempty"`
+	Steps   []StepTrace `json:"steps,omitempty"`
+	Written interface{} `json:"written"`
+}
+
+// StepTrace is the output of one pipeline step
+type StepTrace struct {
+	Transform string      `json:"transform"`
+	Output    interface{} `json:"output"`
+}
+
+// Preview is the result of a dry run
+type Preview struct {
+	Output map[string]interface{} `json:"output"`
+	Trace  []*MappingTrace        `json:"trace"`
+	// Skipped lists the mappings whose condition did not match
+	Skipped []string `json:"skipped"`
+	// Missing lists the input paths with nothing to read
+	Missing []string `json:"missing"`
+	// Errors holds mapping errors and the validation error for missing
+	// required fields
+	Errors []string `json:"errors"`
+}
+
+func newMappingTrace(mapping *models.FieldMapping) *MappingTrace {
+	return &MappingTrace{
+		PlatformID:  mapping.PlatformID,
+		ShopID:      mapping.ShopID,
+		SourceField: mapping.SourceField,
+		TargetField: mapping.TargetField,
+		Condition:   mapping.Condition,
+		Transform:   mapping.Transform,
+	}
+}
+
+func (c *Context) traceValue(vt ValueTrace) {
+	if c.trace != nil {
+		c.trace.Values = append(c.trace.Values, vt)
+	}
+}
+
+// Preview runs Transform (or ReverseTransform) over data without any side
+// effects and traces every mapping. With proposed set, it is used instead of
+// the published mappings as the platform/entity's full set of drafts,
+// including shop mappings and unset entries; inherited mappings still come
+// from the parent platform.
+func (m *Mapper) Preview(platformID, shopID, entityType string, data map[string]interface{}, reverse bool, proposed []*models.FieldMapping) (*Preview, error) {
+	for _, mapping := range proposed {
+		if err := ValidateMapping(mapping); err != nil {
+			return nil, fmt.Errorf("invalid mapping %s: %w", mapping.SourceField, err)
+		}
+	}
+
+	mappings, err := m.candidateMappings(platformID, shopID, entityType, proposed)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := m.getPolicy(platformID, entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{
+		Trace:   []*MappingTrace{},
+		Skipped: []string{},
+		Missing: []string{},
+		Errors:  []string{},
+	}
+
+	if len(mappings) == 0 && policy == nil {
+		if preview.Output, err = m.protect(newScope(platformID, shopID, entityType), data, reverse); err != nil {
+			return nil, err
+		}
+		return preview, nil
+	}
+
+	scope := newScope(platformID, shopID, entityType)
+	output, _, err := m.apply(scope, compilePlan(mappings), policy, data, reverse, &preview.Trace)
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		preview.Errors = append(preview.Errors, err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	if preview.Output, err = m.protect(scope, output, reverse); err != nil {
+		return nil, err
+	}
+
+	for _, t := range preview.Trace {
+		readPath := t.SourceField
+		if reverse {
+			readPath = t.TargetField
+		}
+
+		switch t.Status {
+		case StatusSkipped:
+			preview.Skipped = append(preview.Skipped, readPath)
+		case StatusMissing, StatusDefault, StatusMissingRequired:
+			preview.Missing = append(preview.Missing, readPath)
+		case StatusError:
+			preview.Errors = append(preview.Errors, t.Error)
+		}
+	}
+
+	return preview, nil
+}
+
+// candidateMappings resolves the mappings of a platform/entity: the published
+// ones, or proposed drafts merged over the inherited mappings the same way
+// they would be once published
+func (m *Mapper) candidateMappings(platformID, shopID, entityType string, proposed []*models.FieldMapping) ([]*models.FieldMapping, error) {
+	if proposed == nil {
+		return m.getMappings(platformID, shopID, entityType)
+	}
+
+	var own, shop []*models.FieldMapping
+	for _, mapping := range proposed {
+		if !mapping.IsActive {
+			continue
+		}
+		switch mapping.ShopID {
+		case "":
+			own = append(own, mapping)
+		case shopID:
+			shop = append(shop, mapping)
+		}
+	}
+
+	var inherited []*models.FieldMapping
+	if m.parentRepo != nil {
+		parent, err := m.parentRepo.FindParent(platformID)
+		if err != nil {
+			return nil, err
+		}
+		if parent != "" {
+			if inherited, _, err = m.resolveMappings(parent, entityType, []string{platformID}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	mappings := mergeMappings(inherited, own)
+	if shopID != "" {
+		mappings = mergeMappings(mappings, shop)
+	}
+	return mappings, nil
+}
@@ -0,0 +1,202 @@
+package mapper
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// Transform is a named value conversion that can be used as a step in a
+// mapping's transform pipeline, e.g. `trim|lookup(order_status)`
+type Transform interface {
+	// Forward converts a MercurJS value into its platform representation
+	Forward(ctx *Context, value interface{}, args []string) (interface{}, error)
+	// Inverse converts a platform value back for ReverseTransform
+	Inverse(ctx *Context, value interface{}, args []string) (interface{}, error)
+	// Validate checks the step arguments when a mapping is saved
+	Validate(args []string) error
+}
+
+// Describer is implemented by transforms that document themselves for the
+// transform listing endpoint
+type Describer interface {
+	// Signature shows how the step is written, e.g. `pad_left(width, pad?)`
+	Signature() string
+	Description() string
+}
+
+// Invertible is implemented by transforms that can tell whether Inverse
+// restores every value Forward produced, for the given arguments. Transforms
+// that do not implement it are assumed to be invertible.
+type Invertible interface {
+	Invertible(args []string) bool
+}
+
+// Compiler is implemented by transforms with arguments worth preparing once,
+// such as regex patterns. Compile runs when a mapping's pipeline is compiled;
+// Forward and Inverse get its result from Context.Compiled, so it lives as
+// long as the compiled mapping.
+type Compiler interface {
+	Compile(args []string) (interface{}, error)
+}
+
+// Descriptor describes a registered transform
+type Descriptor struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+}
+
+// Context gives transforms access to the document being mapped and to mapper
+// resources while a pipeline runs. Each mapping gets its own Context.
+type Context struct {
+	mapper  *Mapper
+	scope   mappingScope
+	doc     map[string]interface{}
+	result  map[string]interface{}
+	field   string
+	reverse bool
+	// path is the input path of the value in the pipeline, with wildcards
+	// resolved, or "" when the value does not come from one path
+	path string
+	// compiled is what the running step's transform returned from Compile
+	compiled interface{}
+	// reads are extra input paths consumed by the transforms
+	reads []string
+	// trace records each value passing through the pipeline, for previews
+	trace *MappingTrace
+}
+
+// Document returns the whole input document: the MercurJS payload in
+// Transform, the platform payload in ReverseTransform
+func (c *Context) Document() map[string]interface{} {
+	return c.doc
+}
+
+// Reverse reports whether the pipeline runs for ReverseTransform
+func (c *Context) Reverse() bool {
+	return c.reverse
+}
+
+// Compiled returns what the running step's transform returned from Compile,
+// or nil outside a compiled pipeline
+func (c *Context) Compiled() interface{} {
+	if c == nil {
+		return nil
+	}
+	return c.compiled
+}
+
+// Record stores audit information for the current mapping in the output
+// under section, keyed by the mapping's target field, e.g.
+// {"_exchange_rates": {"price": {...}}}
+func (c *Context) Record(section string, value interface{}) {
+	if c.result == nil {
+		return
+	}
+	records, ok := c.result[section].(map[string]interface{})
+	if !ok {
+		records = make(map[string]interface{})
+		c.result[section] = records
+	}
+	records[c.field] = value
+}
+
+// Recorded returns what Record stored under section for the current mapping,
+// looking it up in the input document. The section is treated as mapped so
+// passthrough policies do not copy it back.
+func (c *Context) Recorded(section string) (interface{}, bool) {
+	records, ok := c.doc[section].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	c.reads = append(c.reads, section)
+	value, ok := records[c.field]
+	return value, ok
+}
+
+// MapEntity applies the mappings of another entity type of the same platform
+// and shop to data, in the pipeline's direction. It fails if the entity type
+// is already being mapped, which would never end.
+func (c *Context) MapEntity(entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	if c == nil || c.mapper == nil {
+		return nil, fmt.Errorf("nested mappings are not available")
+	}
+	scope, err := c.scope.nest(entityType)
+	if err != nil {
+		return nil, err
+	}
+	return c.mapper.transform(scope, data, c.reverse)
+}
+
+// LookupTable returns the named lookup table
+func (c *Context) LookupTable(name string) (*models.LookupTable, error) {
+	if c == nil || c.mapper == nil {
+		return nil, fmt.Errorf("lookup tables are not available")
+	}
+	return c.mapper.getLookupTable(name)
+}
+
+// ExchangeRate returns the rate in effect now for converting from one
+// currency to another
+func (c *Context) ExchangeRate(from, to string) (*models.ExchangeRate, error) {
+	if c == nil || c.mapper == nil {
+		return nil, fmt.Errorf("exchange rates are not available")
+	}
+	return c.mapper.getExchangeRate(from, to)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Transform)
+)
+
+// Register makes a transform available to mapping pipelines under name.
+// It panics if name is invalid or already registered.
+func Register(name string, t Transform) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if !isIdentifier(name) {
+		panic("mapper: invalid transform name " + name)
+	}
+	if t == nil {
+		panic("mapper: Register transform is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("mapper: Register called twice for transform " + name)
+	}
+	registry[name] = t
+}
+
+// GetTransform returns the transform registered under name
+func GetTransform(name string) (Transform, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Transforms lists the registered transforms sorted by name
+func Transforms() []Descriptor {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	descriptors := make([]Descriptor, 0, len(registry))
+	for name, t := range registry {
+		d := Descriptor{Name: name, Signature: name}
+		if describer, ok := t.(Describer); ok {
+			d.Signature = describer.Signature()
+			d.Description = describer.Description()
+		}
+		descriptors = append(descriptors, d)
+	}
+
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].Name < descriptors[j].Name
+	})
+	return descriptors
+}
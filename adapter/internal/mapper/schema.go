@@ -0,0 +1,223 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxSchemaViolations bounds the violations reported for one document
+const maxSchemaViolations = 100
+
+// schemaURL is the location a stored schema is compiled under. Relative
+// `$ref`s resolve against it, so they stay within the schema.
+const schemaURL = "mem:///schema.json"
+
+// Schema is a compiled JSON Schema, draft 2020-12 unless `$schema` names
+// another draft. `format` is asserted. References to other documents are
+// rejected, so validating never fetches anything.
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// SchemaViolation is a place where a document does not match its schema. Path
+// is a dot path with array indexes, e.g. `model_list.2.price`; it is empty
+// for the document itself.
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// CompileSchema parses a JSON Schema document
+func CompileSchema(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := DecodeJSON(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("references to other documents are not supported: %s", url)
+	}
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, schemaCompileError(err)
+	}
+	return &Schema{schema: schema}, nil
+}
+
+// schemaCompileError describes why a schema did not compile: where it breaks
+// the JSON Schema meta-schema, or the underlying error
+func schemaCompileError(err error) error {
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		violations := schemaViolations(validationErr)
+		parts := make([]string, len(violations))
+		for i, v := range violations {
+			if v.Path == "" {
+				parts[i] = v.Message
+			} else {
+				parts[i] = v.Path + ": " + v.Message
+			}
+		}
+		return errors.New(strings.Join(parts, "; "))
+	}
+
+	var compileErr *jsonschema.SchemaError
+	if errors.As(err, &compileErr) && compileErr.Err != nil {
+		err = compileErr.Err
+	}
+	message := strings.TrimPrefix(err.Error(), "jsonschema: ")
+	return errors.New(strings.ReplaceAll(message, schemaURL, ""))
+}
+
+// Validate returns where value does not match the schema, at most
+// maxSchemaViolations of them, sorted by path
+func (s *Schema) Validate(value interface{}) []SchemaViolation {
+	err := s.schema.Validate(value)
+
+	// Transforms may produce Go values the validator does not take, such
+	// as typed slices; their JSON form is validated instead
+	var typeErr jsonschema.InvalidJSONTypeError
+	if errors.As(err, &typeErr) {
+		var normalized interface{}
+		data, marshalErr := json.Marshal(value)
+		if marshalErr == nil {
+			marshalErr = DecodeJSON(data, &normalized)
+		}
+		if marshalErr != nil {
+			return []SchemaViolation{{Message: marshalErr.Error()}}
+		}
+		err = s.schema.Validate(normalized)
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return schemaViolations(validationErr)
+	}
+	if err != nil {
+		return []SchemaViolation{{Message: strings.TrimPrefix(err.Error(), "jsonschema: ")}}
+	}
+	return nil
+}
+
+// schemaViolations flattens a validation error into its causes, the errors
+// that have no causes of their own
+func schemaViolations(err *jsonschema.ValidationError) []SchemaViolation {
+	var violations []SchemaViolation
+	seen := make(map[SchemaViolation]bool)
+
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+		v := SchemaViolation{Path: pointerPath(e.InstanceLocation), Message: e.Message}
+		if !seen[v] {
+			seen[v] = true
+			violations = append(violations, v)
+		}
+	}
+	walk(err)
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	if len(violations) > maxSchemaViolations {
+		violations = violations[:maxSchemaViolations]
+	}
+	return violations
+}
+
+// pointerPath turns a JSON pointer such as `/model_list/2/price` into the dot
+// path `model_list.2.price`
+func pointerPath(pointer string) string {
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return ""
+	}
+	parts := strings.Split(pointer, "/")
+	for i, part := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ValidateOutput checks data mapped for a platform/entity against the schema
+// registered for the direction it was mapped in: the platform's schema for
+// Transform output, the MercurJS one for ReverseTransform output. It returns
+// a *SchemaError listing the violations, or nil when no schema is registered.
+func (m *Mapper) ValidateOutput(platformID, entityType string, reverse bool, data map[string]interface{}) error {
+	direction := models.SchemaForward
+	if reverse {
+		direction = models.SchemaReverse
+	}
+
+	schema, err := m.getSchema(platformID, entityType, direction)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	if violations := schema.Validate(data); len(violations) > 0 {
+		return &SchemaError{Direction: direction, Violations: violations}
+	}
+	return nil
+}
+
+// getSchema returns the compiled schema of a platform/entity/direction, or
+// nil when none is registered
+func (m *Mapper) getSchema(platformID, entityType, direction string) (*Schema, error) {
+	cacheKey := platformID + ":" + entityType + ":" + direction
+
+	m.mu.RLock()
+	generation := m.generation
+	if cached, ok := m.schemaCache[cacheKey]; ok && m.fresh(cached.fetchedAt) {
+		m.mu.RUnlock()
+		return cached.schema, cached.err
+	}
+	m.mu.RUnlock()
+
+	if m.schemaRepo == nil {
+		return nil, nil
+	}
+
+	fetchedAt := time.Now()
+	row, err := m.schemaRepo.Find(platformID, entityType, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stored schema that no longer compiles is cached with its error, so
+	// it is not parsed again for every document
+	var schema *Schema
+	if row != nil {
+		if schema, err = CompileSchema(row.Schema); err != nil {
+			err = fmt.Errorf("invalid %s schema for %s/%s: %w", direction, platformID, entityType, err)
+		}
+	}
+
+	m.mu.Lock()
+	if m.generation == generation {
+		m.schemaCache[cacheKey] = &cachedSchema{schema: schema, err: err, fetchedAt: fetchedAt}
+	}
+	m.mu.Unlock()
+
+	return schema, err
+}
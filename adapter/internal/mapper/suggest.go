@@ -0,0 +1,429 @@
+package mapper
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// minSuggestionScore is the lowest score of a suggested mapping, e.g. a
+// partial name match (`sku` and `item_sku`) of two values of the same type
+const minSuggestionScore = 0.4
+
+// suggestionTransforms are the transforms tried when a source value differs
+// from the target value, in order of preference
+var suggestionTransforms = []string{
+	"trim",
+	"lowercase",
+	"uppercase",
+	"cents_to_dollars",
+	"dollars_to_cents",
+	"string",
+	"int",
+	"date_iso",
+	"date(unix, rfc3339)",
+	"date(rfc3339, unix)",
+	"date(unix_ms, rfc3339)",
+	"date(rfc3339, unix_ms)",
+	"slugify",
+}
+
+// suggestionSteps are the parsed suggestionTransforms. Transforms register in
+// init functions, so they are parsed on first use.
+var (
+	suggestionSteps     [][]step
+	suggestionStepsOnce sync.Once
+)
+
+func parsedSuggestionTransforms() [][]step {
+	suggestionStepsOnce.Do(func() {
+		suggestionSteps = make([][]step, len(suggestionTransforms))
+		for i, transform := range suggestionTransforms {
+			suggestionSteps[i], _ = parsePipeline(transform)
+		}
+	})
+	return suggestionSteps
+}
+
+// nameAliases expands common abbreviations in field names
+var nameAliases = map[string]string{
+	"addr": "address",
+	"amt":  "amount",
+	"cat":  "category",
+	"desc": "description",
+	"img":  "image",
+	"no":   "number",
+	"num":  "number",
+	"qty":  "quantity",
+}
+
+// Suggestion is a mapping proposed from a pair of sample payloads. Its fields
+// are those of POST /api/mappings, so it can be previewed or saved as is.
+type Suggestion struct {
+	SourceField string   `json:"source_field"`
+	TargetField string   `json:"target_field"`
+	Transform   string   `json:"transform,omitempty"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+}
+
+// Suggestions are the mappings proposed for two sample payloads, best first,
+// and the leaf paths of each payload left without one
+type Suggestions struct {
+	Mappings        []Suggestion `json:"mappings"`
+	UnmatchedSource []string     `json:"unmatched_source"`
+	UnmatchedTarget []string     `json:"unmatched_target"`
+}
+
+// Suggest proposes mappings for the fields of a platform/entity's sample
+// payloads that its mapping set, resolved as in Lint, does not cover yet
+func (m *Mapper) Suggest(platformID, shopID, entityType string, source, target map[string]interface{}, proposed []*models.FieldMapping) (*Suggestions, error) {
+	mappings, err := m.candidateMappings(platformID, shopID, entityType, proposed)
+	if err != nil {
+		return nil, err
+	}
+	return SuggestMappings(source, target, mappings), nil
+}
+
+// suggestionField is a leaf of a sample payload
+type suggestionField struct {
+	path      string
+	example   interface{}
+	kind      string
+	wildcards int
+	leaf      []string
+	tokens    []string
+}
+
+// SuggestMappings matches the leaves of a sample MercurJS payload to those of
+// a sample platform payload by name, type and value. Each leaf is used by at
+// most one suggestion. Leaves read or written by existing are left out.
+func SuggestMappings(source, target map[string]interface{}, existing []*models.FieldMapping) *Suggestions {
+	read, written := mappedPaths(existing)
+	sources := suggestionFields(source, read)
+	targets := suggestionFields(target, written)
+
+	var candidates []Suggestion
+	for _, src := range sources {
+		for _, tgt := range targets {
+			if s, ok := suggestPair(src, tgt); ok {
+				candidates = append(candidates, s)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.SourceField != b.SourceField {
+			return a.SourceField < b.SourceField
+		}
+		return a.TargetField < b.TargetField
+	})
+
+	usedSource := make(map[string]bool)
+	usedTarget := make(map[string]bool)
+	suggestions := &Suggestions{Mappings: []Suggestion{}, UnmatchedSource: []string{}, UnmatchedTarget: []string{}}
+	for _, s := range candidates {
+		if usedSource[s.SourceField] || usedTarget[s.TargetField] {
+			continue
+		}
+		usedSource[s.SourceField] = true
+		usedTarget[s.TargetField] = true
+		suggestions.Mappings = append(suggestions.Mappings, s)
+	}
+
+	for _, src := range sources {
+		if !usedSource[src.path] {
+			suggestions.UnmatchedSource = append(suggestions.UnmatchedSource, src.path)
+		}
+	}
+	for _, tgt := range targets {
+		if !usedTarget[tgt.path] {
+			suggestions.UnmatchedTarget = append(suggestions.UnmatchedTarget, tgt.path)
+		}
+	}
+	return suggestions
+}
+
+// mappedPaths returns the source paths the active mappings read and the
+// target paths they write, with array indexes written as `*`
+func mappedPaths(mappings []*models.FieldMapping) (map[string]bool, map[string]bool) {
+	read := make(map[string]bool)
+	written := make(map[string]bool)
+	for _, mapping := range mappings {
+		if !mapping.IsActive || mapping.Unset {
+			continue
+		}
+
+		sources := []string{mapping.SourceField}
+		if isTemplate(mapping.SourceField) {
+			if tpl, err := parseTemplate(mapping.SourceField); err == nil {
+				sources = tpl.paths
+			}
+		}
+		for _, path := range sources {
+			read[normalizeIndexes(strings.Split(path, "."))] = true
+		}
+		for _, path := range forwardPaths(mapping) {
+			written[normalizeIndexes(strings.Split(path, "."))] = true
+		}
+	}
+	return read, written
+}
+
+// suggestionFields flattens a sample payload into its leaves, sorted by path,
+// leaving out those covered by mapped
+func suggestionFields(data map[string]interface{}, mapped map[string]bool) []suggestionField {
+	examples := make(map[string]interface{})
+	for key, value := range data {
+		collectLeaves(key, value, examples)
+	}
+
+	fields := make([]suggestionField, 0, len(examples))
+	for path, example := range examples {
+		if isCovered(path, mapped) {
+			continue
+		}
+
+		segments := strings.Split(path, ".")
+		field := suggestionField{path: path, example: example, kind: valueKind(example)}
+		for _, segment := range segments {
+			if segment == "*" {
+				field.wildcards++
+				continue
+			}
+			field.tokens = append(field.tokens, nameTokens(segment)...)
+		}
+		field.leaf = nameTokens(leafName(segments))
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].path < fields[j].path
+	})
+	return fields
+}
+
+// leafName returns the last segment of a path that is not an array index
+func leafName(segments []string) string {
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != "*" {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+// suggestPair scores mapping src to tgt. Both must be as deep in arrays, so
+// the mapping copies element by element.
+func suggestPair(src, tgt suggestionField) (Suggestion, bool) {
+	if src.wildcards != tgt.wildcards {
+		return Suggestion{}, false
+	}
+
+	s := Suggestion{SourceField: src.path, TargetField: tgt.path, Reasons: []string{}}
+
+	name := nameScore(src, tgt)
+	switch {
+	case name == 1:
+		s.Reasons = append(s.Reasons, "same name")
+	case name > 0:
+		s.Reasons = append(s.Reasons, "similar name")
+	}
+
+	var value float64
+	if transform, ok := matchValues(src.example, tgt.example); ok {
+		// Common values such as true, 0 or "" match too easily on their own
+		distinctive := isDistinctive(src.example) || isDistinctive(tgt.example)
+		if !distinctive && name == 0 {
+			return Suggestion{}, false
+		}
+
+		s.Transform = transform
+		value = 1
+		if !distinctive {
+			value = 0.3
+		}
+		if transform == "" {
+			s.Reasons = append(s.Reasons, "same value")
+		} else {
+			s.Reasons = append(s.Reasons, "values match after "+transform)
+		}
+	}
+
+	score := 0.6*name + 0.4*value
+	if value == 0 {
+		// Without a value match the types must agree; a null example could
+		// be anything
+		switch {
+		case src.kind == tgt.kind && src.kind != "null":
+			score += 0.1
+			s.Reasons = append(s.Reasons, "same type")
+		case src.kind != "null" && tgt.kind != "null":
+			return Suggestion{}, false
+		}
+	}
+
+	s.Score = math.Round(score*100) / 100
+	return s, s.Score >= minSuggestionScore
+}
+
+// nameScore compares the names of two fields: 1 when their leaf names or
+// whole paths are the same once split into words, else the share of leaf
+// words in common
+func nameScore(src, tgt suggestionField) float64 {
+	if len(src.leaf) > 0 && strings.Join(src.leaf, "") == strings.Join(tgt.leaf, "") {
+		return 1
+	}
+	if len(src.tokens) > 0 && strings.Join(src.tokens, "") == strings.Join(tgt.tokens, "") {
+		return 1
+	}
+	return math.Max(tokenOverlap(src.leaf, tgt.leaf), tokenOverlap(src.tokens, tgt.tokens)*0.8)
+}
+
+// tokenOverlap returns the words a and b have in common over the words of the
+// longer one
+func tokenOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	words := make(map[string]bool, len(a))
+	for _, token := range a {
+		words[token] = true
+	}
+	common := 0
+	for _, token := range b {
+		if words[token] {
+			common++
+			delete(words, token)
+		}
+	}
+
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return float64(common) / float64(longest)
+}
+
+// nameTokens splits a field name into lower-case words at underscores,
+// dashes, spaces and camelCase humps, e.g. `itemSKU_code` -> item, sku, code.
+// Abbreviations are expanded and plural words made singular.
+func nameTokens(name string) []string {
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		tokens = append(tokens, normalizeToken(strings.ToLower(string(current))))
+		current = current[:0]
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return tokens
+}
+
+func normalizeToken(token string) string {
+	if alias, ok := nameAliases[token]; ok {
+		return alias
+	}
+	if len(token) > 3 && strings.HasSuffix(token, "s") && !strings.HasSuffix(token, "ss") {
+		return strings.TrimSuffix(token, "s")
+	}
+	return token
+}
+
+// matchValues reports whether a source value equals a target value as is, or
+// after one of the suggestionTransforms, which it returns
+func matchValues(src, tgt interface{}) (string, bool) {
+	if sameValue(src, tgt) {
+		return "", true
+	}
+	if src == nil || tgt == nil {
+		return "", false
+	}
+
+	for i, steps := range parsedSuggestionTransforms() {
+		if steps == nil {
+			continue
+		}
+		out, err := applySteps(&Context{}, src, steps)
+		if err == nil && sameValue(out, tgt) {
+			return suggestionTransforms[i], true
+		}
+	}
+	return "", false
+}
+
+// sameValue compares two values of the same kind. Numbers are compared
+// exactly, so json.Number("19.90") equals 19.9. Nulls never match.
+func sameValue(a, b interface{}) bool {
+	kind := valueKind(a)
+	if kind != valueKind(b) || kind == "null" {
+		return false
+	}
+	if kind == "number" {
+		ra, okA := toDecimal(a)
+		rb, okB := toDecimal(b)
+		return okA && okB && ra.Cmp(rb) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// isDistinctive reports whether a value is unlikely to be shared by unrelated
+// fields: a string of 3 or more characters, or a number other than 0 and 1
+func isDistinctive(v interface{}) bool {
+	switch valueKind(v) {
+	case "string":
+		return utf8.RuneCountInString(strings.TrimSpace(v.(string))) >= 3
+	case "number":
+		r, ok := toDecimal(v)
+		return ok && r.Sign() != 0 && r.Cmp(big.NewRat(1, 1)) != 0
+	}
+	return false
+}
+
+// valueKind returns the JSON type of a value
+func valueKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := toDecimal(v); ok {
+		return "number"
+	}
+	return "unknown"
+}
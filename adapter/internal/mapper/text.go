@@ -0,0 +1,244 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+func validatePatternArg(args []string) error {
+	_, err := compilePattern(args[0])
+	return err
+}
+
+// compilePatternArg compiles the pattern argument once per compiled pipeline
+func compilePatternArg(args []string) (interface{}, error) {
+	return compilePattern(args[0])
+}
+
+// stepPattern returns the pattern the step compiled, or compiles it when the
+// transform runs outside a compiled pipeline
+func stepPattern(ctx *Context, args []string) (*regexp.Regexp, error) {
+	if re, ok := ctx.Compiled().(*regexp.Regexp); ok {
+		return re, nil
+	}
+	return compilePattern(args[0])
+}
+
+func init() {
+	Register("regex_replace", &builtin{
+		signature:   "regex_replace(pattern, replacement)",
+		description: "Replaces every match of pattern in a string; replacement may refer to groups as $1 or ${name} (lossy)",
+		minArgs:     2,
+		maxArgs:     2,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return value, nil
+			}
+			re, err := stepPattern(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			return re.ReplaceAllString(s, args[1]), nil
+		},
+		inverse:  identity,
+		validate: validatePatternArg,
+		compile:  compilePatternArg,
+		lossy:    alwaysLossy,
+	})
+
+	Register("regex_extract", &builtin{
+		signature:   "regex_extract(pattern, group?)",
+		description: "Returns the first match of pattern in a string, or its capture group (group 1 by default when the pattern has one). Nothing is written when there is no match (lossy)",
+		minArgs:     1,
+		maxArgs:     2,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return value, nil
+			}
+			re, err := stepPattern(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			groups := re.FindStringSubmatch(s)
+			if groups == nil {
+				return nil, nil
+			}
+			return groups[extractGroup(re, args)], nil
+		},
+		inverse: identity,
+		validate: func(args []string) error {
+			re, err := compilePattern(args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) > 1 {
+				if n, err := strconv.Atoi(args[1]); err != nil || n < 0 || n > re.NumSubexp() {
+					return fmt.Errorf("group must be between 0 and %d", re.NumSubexp())
+				}
+			}
+			return nil
+		},
+		compile: compilePatternArg,
+		lossy:   alwaysLossy,
+	})
+
+	Register("truncate", &builtin{
+		signature:   "truncate(length, suffix?)",
+		description: `Shortens a string to at most length characters, ending with suffix (e.g. "...") when cut (lossy)`,
+		minArgs:     1,
+		maxArgs:     2,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			s, ok := value.(string)
+			if !ok {
+				return value
+			}
+			length, _ := strconv.Atoi(args[0])
+			suffix := ""
+			if len(args) > 1 {
+				suffix = args[1]
+			}
+			return truncate(s, length, suffix)
+		}),
+		inverse: identity,
+		validate: func(args []string) error {
+			if n, err := strconv.Atoi(args[0]); err != nil || n < 0 {
+				return fmt.Errorf("length must be a non-negative integer")
+			}
+			return nil
+		},
+		lossy: alwaysLossy,
+	})
+
+	Register("slugify", &builtin{
+		signature:   "slugify",
+		description: `Lower-cases a string and joins its letters and digits with "-", e.g. "Blue Shirt (XL)" -> "blue-shirt-xl" (lossy)`,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if s, ok := value.(string); ok {
+				return slugify(s)
+			}
+			return value
+		}),
+		inverse: identity,
+		lossy:   alwaysLossy,
+	})
+
+	Register("split", &builtin{
+		signature:   "split(separator)",
+		description: "Splits a string into an array; the inverse joins it back",
+		minArgs:     1,
+		maxArgs:     1,
+		forward:     scalar(splitString),
+		inverse:     scalar(joinList),
+		validate:    validateSeparatorArg,
+		list:        true,
+	})
+
+	Register("join", &builtin{
+		signature:   "join(separator)",
+		description: "Joins the elements of an array into a string; the inverse splits it back",
+		minArgs:     1,
+		maxArgs:     1,
+		forward:     scalar(joinList),
+		inverse:     scalar(splitString),
+		validate:    validateSeparatorArg,
+		list:        true,
+	})
+}
+
+// extractGroup returns the group regex_extract returns: args[1], or 1 when
+// the pattern has a capture group and 0 (the whole match) otherwise
+func extractGroup(re *regexp.Regexp, args []string) int {
+	if len(args) > 1 {
+		group, _ := strconv.Atoi(args[1])
+		return group
+	}
+	if re.NumSubexp() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// truncate cuts s to length runes, the suffix included
+func truncate(s string, length int, suffix string) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+
+	cut := length - len([]rune(suffix))
+	if cut < 0 {
+		return string(runes[:length])
+	}
+	return string(runes[:cut]) + suffix
+}
+
+func slugify(s string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = true
+			continue
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		separate = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func validateSeparatorArg(args []string) error {
+	if args[0] == "" {
+		return fmt.Errorf("separator must not be empty")
+	}
+	return nil
+}
+
+// splitString splits a string on args[0]. An empty string gives an empty
+// array; other values are passed through.
+func splitString(value interface{}, args []string) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if s == "" {
+		return []interface{}{}
+	}
+
+	parts := strings.Split(s, args[0])
+	list := make([]interface{}, len(parts))
+	for i, part := range parts {
+		list[i] = part
+	}
+	return list
+}
+
+// joinList joins the string forms of an array's elements with args[0]. Other
+// values are passed through.
+func joinList(value interface{}, args []string) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+
+	parts := make([]string, len(list))
+	for i, elem := range list {
+		parts[i] = toString(elem)
+	}
+	return strings.Join(parts, args[0])
+}
@@ -0,0 +1,339 @@
+package mapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// transformFunc is the signature shared by Forward and Inverse
+type transformFunc func(ctx *Context, value interface{}, args []string) (interface{}, error)
+
+// builtin adapts plain functions to the Transform interface
+type builtin struct {
+	signature   string
+	description string
+	minArgs     int
+	maxArgs     int
+	forward     transformFunc
+	// inverse defaults to forward when nil
+	inverse  transformFunc
+	validate func(args []string) error
+	// compile prepares the arguments once per compiled pipeline, see Compiler
+	compile func(args []string) (interface{}, error)
+	// lossy reports whether inverse cannot restore what forward produced
+	lossy func(args []string) bool
+	// list transforms take a whole array; the others are applied to each
+	// element of an array
+	list bool
+}
+
+func (b *builtin) Forward(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	return b.lift(b.forward, ctx, value, args)
+}
+
+func (b *builtin) Inverse(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	if b.inverse == nil {
+		return b.lift(b.forward, ctx, value, args)
+	}
+	return b.lift(b.inverse, ctx, value, args)
+}
+
+// lift applies fn to each element of an array value, keeping nil elements,
+// unless the transform takes whole arrays
+func (b *builtin) lift(fn transformFunc, ctx *Context, value interface{}, args []string) (interface{}, error) {
+	arr, ok := value.([]interface{})
+	if !ok || b.list {
+		return fn(ctx, value, args)
+	}
+
+	out := make([]interface{}, len(arr))
+	for i, elem := range arr {
+		if elem == nil {
+			continue
+		}
+		mapped, err := fn(ctx, elem, args)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = mapped
+	}
+	return out, nil
+}
+
+func (b *builtin) Validate(args []string) error {
+	if len(args) < b.minArgs || len(args) > b.maxArgs {
+		switch {
+		case b.maxArgs == 0:
+			return fmt.Errorf("takes no arguments")
+		case b.minArgs == b.maxArgs:
+			return fmt.Errorf("expects %d argument(s): %s", b.minArgs, b.signature)
+		default:
+			return fmt.Errorf("expects %d to %d arguments: %s", b.minArgs, b.maxArgs, b.signature)
+		}
+	}
+	if b.validate != nil {
+		return b.validate(args)
+	}
+	return nil
+}
+
+func (b *builtin) Compile(args []string) (interface{}, error) {
+	if b.compile == nil {
+		return nil, nil
+	}
+	return b.compile(args)
+}
+
+func (b *builtin) Invertible(args []string) bool {
+	return b.lossy == nil || !b.lossy(args)
+}
+
+func (b *builtin) Signature() string {
+	return b.signature
+}
+
+func (b *builtin) Description() string {
+	return b.description
+}
+
+// scalar wraps a function that cannot fail
+func scalar(fn func(value interface{}, args []string) interface{}) transformFunc {
+	return func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+		return fn(value, args), nil
+	}
+}
+
+// identity is the inverse of lossy transforms whose input cannot be recovered
+func identity(ctx *Context, value interface{}, args []string) (interface{}, error) {
+	return value, nil
+}
+
+// alwaysLossy marks transforms that lose information whatever their arguments
+func alwaysLossy(args []string) bool {
+	return true
+}
+
+func init() {
+	Register("uppercase", &builtin{
+		signature:   "uppercase",
+		description: "Converts a string to upper case",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if s, ok := value.(string); ok {
+				return strings.ToUpper(s)
+			}
+			return value
+		}),
+		lossy: alwaysLossy,
+	})
+
+	Register("lowercase", &builtin{
+		signature:   "lowercase",
+		description: "Converts a string to lower case",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if s, ok := value.(string); ok {
+				return strings.ToLower(s)
+			}
+			return value
+		}),
+		lossy: alwaysLossy,
+	})
+
+	Register("trim", &builtin{
+		signature:   "trim",
+		description: "Removes leading and trailing whitespace (lossy)",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			if s, ok := value.(string); ok {
+				return strings.TrimSpace(s)
+			}
+			return value
+		}),
+		inverse: identity,
+		lossy:   alwaysLossy,
+	})
+
+	Register("cents_to_dollars", &builtin{
+		signature:   "cents_to_dollars(mode?)",
+		description: "Divides an amount in minor units by 100 exactly; mode rounds the inverse back to whole cents",
+		maxArgs:     1,
+		forward:     scalar(centsToDollars),
+		inverse:     scalar(dollarsToCents),
+		validate:    validateRoundingModeArg(0),
+	})
+
+	Register("dollars_to_cents", &builtin{
+		signature:   "dollars_to_cents(mode?)",
+		description: "Multiplies an amount by 100 and rounds to whole cents (half_up by default)",
+		maxArgs:     1,
+		forward:     scalar(dollarsToCents),
+		inverse:     scalar(centsToDollars),
+		validate:    validateRoundingModeArg(0),
+	})
+
+	Register("round", &builtin{
+		signature:   "round(places, mode?)",
+		description: "Rounds a number to the given decimal places, half_up by default (lossy)",
+		minArgs:     1,
+		maxArgs:     2,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			r, ok := toDecimal(value)
+			if !ok {
+				return value
+			}
+			places, _ := strconv.Atoi(args[0])
+			return fromDecimal(roundDecimal(r, places, roundingModeArg(args, 1)))
+		}),
+		inverse: identity,
+		validate: func(args []string) error {
+			if n, err := strconv.Atoi(args[0]); err != nil || n < 0 {
+				return fmt.Errorf("decimal places must be a non-negative integer")
+			}
+			return validateRoundingModeArg(1)(args)
+		},
+		lossy: alwaysLossy,
+	})
+
+	Register("pad_left", &builtin{
+		signature:   "pad_left(width, pad?)",
+		description: "Left-pads the string form of a value to width characters, with spaces by default (lossy)",
+		minArgs:     1,
+		maxArgs:     2,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			width, _ := strconv.Atoi(args[0])
+			pad := padArg(args)
+			s := toString(value)
+			for utf8.RuneCountInString(s) < width {
+				s = pad + s
+			}
+			return s
+		}),
+		inverse: scalar(func(value interface{}, args []string) interface{} {
+			pad := padArg(args)
+			s, ok := value.(string)
+			if !ok {
+				return value
+			}
+			if trimmed := strings.TrimLeft(s, pad); trimmed != "" {
+				return trimmed
+			}
+			return pad
+		}),
+		validate: func(args []string) error {
+			if n, err := strconv.Atoi(args[0]); err != nil || n < 0 {
+				return fmt.Errorf("width must be a non-negative integer")
+			}
+			if len(args) > 1 && args[1] == "" {
+				return fmt.Errorf("pad must not be empty")
+			}
+			return nil
+		},
+		// The inverse strips every leading pad, including ones that were part
+		// of the value, e.g. "00123" comes back as "123"
+		lossy: alwaysLossy,
+	})
+
+	Register("string", &builtin{
+		signature:   "string",
+		description: "Converts a value to its string form",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			return toString(value)
+		}),
+		lossy: alwaysLossy,
+	})
+
+	Register("int", &builtin{
+		signature:   "int",
+		description: "Converts a value to an integer",
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			return toInt(value)
+		}),
+		lossy: alwaysLossy,
+	})
+
+	Register("bool", &builtin{
+		signature:   "bool",
+		description: `Converts a value to a boolean ("true", "1", "yes" and non-zero numbers are true)`,
+		forward: scalar(func(value interface{}, args []string) interface{} {
+			return toBool(value)
+		}),
+		lossy: alwaysLossy,
+	})
+
+	Register("lookup", &builtin{
+		signature:   "lookup(table)",
+		description: "Translates a value through a lookup table, and back through its inverse",
+		minArgs:     1,
+		maxArgs:     1,
+		forward: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return applyLookup(ctx, value, args[0], false)
+		},
+		inverse: func(ctx *Context, value interface{}, args []string) (interface{}, error) {
+			return applyLookup(ctx, value, args[0], true)
+		},
+	})
+}
+
+// centsToDollars divides by 100. The result is always exact, so it needs no
+// rounding mode.
+func centsToDollars(value interface{}, args []string) interface{} {
+	r, ok := toDecimal(value)
+	if !ok {
+		return value
+	}
+	return fromDecimal(r.Quo(r, pow10(2)))
+}
+
+// dollarsToCents multiplies by 100 and rounds to a whole number of cents with
+// the rounding mode in args[0]
+func dollarsToCents(value interface{}, args []string) interface{} {
+	r, ok := toDecimal(value)
+	if !ok {
+		return value
+	}
+	return fromDecimal(roundDecimal(r.Mul(r, pow10(2)), 0, roundingModeArg(args, 0)))
+}
+
+// roundingModeArg returns the rounding mode at args[i], or the default
+func roundingModeArg(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+	return defaultRoundingMode
+}
+
+func validateRoundingModeArg(i int) func(args []string) error {
+	return func(args []string) error {
+		if len(args) > i && !isValidRoundingMode(args[i]) {
+			return fmt.Errorf("unknown rounding mode %q (expected half_up, half_even, down, up, floor or ceiling)", args[i])
+		}
+		return nil
+	}
+}
+
+func padArg(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return " "
+}
+
+// applyLookup translates a value through a named lookup table. Values with no
+// entry and no configured default are passed through unchanged.
+func applyLookup(ctx *Context, value interface{}, tableName string, reverse bool) (interface{}, error) {
+	table, err := ctx.LookupTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := toString(value)
+	translate := table.Translate
+	if reverse {
+		translate = table.TranslateReverse
+	}
+
+	if translated, ok := translate(key); ok {
+		return translated, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,119 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// ValidateMapping checks that a mapping is well formed before it is saved:
+// field paths, template/split syntax, transform pipeline, condition and
+// default value
+func ValidateMapping(mapping *models.FieldMapping) error {
+	if mapping.Unset {
+		return validateUnset(mapping)
+	}
+
+	switch {
+	case mapping.SplitPattern != "":
+		if isTemplate(mapping.SourceField) {
+			return fmt.Errorf("split mappings cannot use a template source_field")
+		}
+		if err := validatePath(mapping.SourceField); err != nil {
+			return fmt.Errorf("source_field: %w", err)
+		}
+		if countWildcards(mapping.SourceField) > 0 {
+			return fmt.Errorf("source_field: wildcards are not supported in split mappings")
+		}
+		if _, err := parseSplit(mapping.SplitPattern, mapping.TargetField); err != nil {
+			return fmt.Errorf("split: %w", err)
+		}
+	case isTemplate(mapping.SourceField):
+		if _, err := parseTemplate(mapping.SourceField); err != nil {
+			return fmt.Errorf("source_field: %w", err)
+		}
+		if err := validatePath(mapping.TargetField); err != nil {
+			return fmt.Errorf("target_field: %w", err)
+		}
+		if countWildcards(mapping.TargetField) > 0 {
+			return fmt.Errorf("target_field: wildcards are not supported in template mappings")
+		}
+	default:
+		if err := validatePaths(mapping.SourceField, mapping.TargetField); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidatePipeline(mapping.Transform); err != nil {
+		return fmt.Errorf("transform: %w", err)
+	}
+
+	if err := ValidateCondition(mapping.Condition); err != nil {
+		return fmt.Errorf("condition: %w", err)
+	}
+
+	if mapping.DefaultValue != "" {
+		if !json.Valid([]byte(mapping.DefaultValue)) {
+			return fmt.Errorf("default_value must be valid JSON")
+		}
+		if mapping.SplitPattern != "" || countWildcards(mapping.TargetField) > 0 {
+			return fmt.Errorf("default_value cannot be used with split or wildcard targets")
+		}
+	}
+
+	return nil
+}
+
+// validateUnset checks an unset entry, which only needs the source field and
+// condition that identify the inherited mapping it removes
+func validateUnset(mapping *models.FieldMapping) error {
+	if isTemplate(mapping.SourceField) {
+		if _, err := parseTemplate(mapping.SourceField); err != nil {
+			return fmt.Errorf("source_field: %w", err)
+		}
+	} else if err := validatePath(mapping.SourceField); err != nil {
+		return fmt.Errorf("source_field: %w", err)
+	}
+
+	if err := ValidateCondition(mapping.Condition); err != nil {
+		return fmt.Errorf("condition: %w", err)
+	}
+
+	if mapping.Transform != "" || mapping.SplitPattern != "" || mapping.DefaultValue != "" || mapping.Required {
+		return fmt.Errorf("unset mappings cannot have a transform, split_pattern, default_value or required")
+	}
+
+	return nil
+}
+
+// validatePaths checks that a source/target path pair is well formed and that
+// their wildcards can be matched up by copyField
+func validatePaths(source, target string) error {
+	if err := validatePath(source); err != nil {
+		return fmt.Errorf("source_field: %w", err)
+	}
+	if err := validatePath(target); err != nil {
+		return fmt.Errorf("target_field: %w", err)
+	}
+
+	sourceWildcards := countWildcards(source)
+	targetWildcards := countWildcards(target)
+	if sourceWildcards == targetWildcards || targetWildcards == 0 || (sourceWildcards == 0 && targetWildcards == 1) {
+		return nil
+	}
+	return fmt.Errorf("source_field has %d wildcard(s) but target_field has %d", sourceWildcards, targetWildcards)
+}
+
+func validatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("path must not be empty")
+	}
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			return fmt.Errorf("invalid path %q: empty segment", path)
+		}
+	}
+	return nil
+}
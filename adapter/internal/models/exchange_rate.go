@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ExchangeRate is the price of one unit of BaseCurrency in QuoteCurrency from
+// EffectiveAt until the next rate for the same pair
+type ExchangeRate struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	// Rate is an exact decimal string, e.g. "0.027412"
+	Rate        string
+	EffectiveAt time.Time
+	CreatedAt   time.Time
+}
+
+// IsValidCurrencyCode reports whether code looks like an ISO 4217 code, e.g. "USD"
+func IsValidCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -3,12 +3,24 @@ package models
 import "time"
 
 type FieldMapping struct {
-	ID          string
-	PlatformID  string
+	ID         string
+	PlatformID string
+	// ShopID scopes the mapping to one shop of the platform; "" is platform-wide
+	ShopID      string
 	EntityType  string
 	SourceField string
 	TargetField string
 	Transform   string
-	IsActive    bool
-	CreatedAt   time.Time
+	// SplitPattern fans SourceField out into the comma-separated TargetField
+	// paths, using a literal separator or a /regex/ with one group per target
+	SplitPattern string
+	Condition    string
+	// DefaultValue is a JSON literal written to TargetField when SourceField is missing
+	DefaultValue string
+	Required     bool
+	// Unset removes the mapping with the same SourceField and Condition
+	// inherited from the parent platform; TargetField is ignored
+	Unset     bool
+	IsActive  bool
+	CreatedAt time.Time
 }
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// LookupTable translates values between MercurJS and a platform, e.g. order
+// status or carrier codes
+type LookupTable struct {
+	ID   string
+	Name string
+	// DefaultValue is returned for values with no entry; nil passes them through
+	DefaultValue *string
+	// ReverseDefaultValue is the inverse counterpart of DefaultValue
+	ReverseDefaultValue *string
+	Entries             []LookupEntry
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type LookupEntry struct {
+	Source string
+	Target string
+}
+
+// Translate maps a source value to its target value
+func (t *LookupTable) Translate(value string) (string, bool) {
+	for _, e := range t.Entries {
+		if e.Source == value {
+			return e.Target, true
+		}
+	}
+	if t.DefaultValue != nil {
+		return *t.DefaultValue, true
+	}
+	return "", false
+}
+
+// TranslateReverse maps a target value back to its source value. When several
+// sources share a target, the first entry wins.
+func (t *LookupTable) TranslateReverse(value string) (string, bool) {
+	for _, e := range t.Entries {
+		if e.Target == value {
+			return e.Source, true
+		}
+	}
+	if t.ReverseDefaultValue != nil {
+		return *t.ReverseDefaultValue, true
+	}
+	return "", false
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// CoveragePath counts how often a source path appeared in the sampled payloads
+// of a platform/entity, and how often a mapping read it
+type CoveragePath struct {
+	PlatformID string
+	EntityType string
+	// Path has array indexes written as `*`, e.g. "items.*.sku"
+	Path        string
+	SeenCount   int64
+	MappedCount int64
+	// Frequency is SeenCount over the platform/entity's samples
+	Frequency float64
+	// Example is the JSON of a recent value
+	Example     string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// CoverageSamples is the number of payloads sampled for a platform/entity
+type CoverageSamples struct {
+	PlatformID string
+	EntityType string
+	Samples    int64
+	UpdatedAt  time.Time
+}
+
+// CoverageReport summarizes which sampled source paths of a platform/entity
+// the mappings use
+type CoverageReport struct {
+	PlatformID  string
+	EntityType  string
+	Samples     int64
+	SeenPaths   int
+	MappedPaths int
+	// Coverage is MappedPaths over SeenPaths
+	Coverage float64
+	// Unmapped paths were never read by a mapping, most frequent first
+	Unmapped []*CoveragePath
+	// PartiallyMapped paths were read in some samples only, e.g. by a
+	// conditional mapping
+	PartiallyMapped []*CoveragePath
+	UpdatedAt       time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// MappingParent makes a platform inherit the field mappings of another
+// platform. The platform's own mappings override inherited ones with the same
+// source field and condition.
+type MappingParent struct {
+	PlatformID       string
+	ParentPlatformID string
+	UpdatedAt        time.Time
+}
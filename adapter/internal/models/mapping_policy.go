@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Unmapped field modes for a MappingPolicy
+const (
+	UnmappedStrict            = "strict"
+	UnmappedPassthrough       = "passthrough"
+	UnmappedPassthroughExcept = "passthrough_except"
+)
+
+// MappingPolicy controls what happens to fields that no mapping mentions for
+// a platform/entity
+type MappingPolicy struct {
+	PlatformID     string
+	EntityType     string
+	UnmappedFields string
+	// ExceptPaths are stripped from the output in passthrough_except mode
+	ExceptPaths []string
+	UpdatedAt   time.Time
+}
+
+// IsValidUnmappedMode reports whether mode is a known unmapped field mode
+func IsValidUnmappedMode(mode string) bool {
+	switch mode {
+	case UnmappedStrict, UnmappedPassthrough, UnmappedPassthroughExcept:
+		return true
+	}
+	return false
+}
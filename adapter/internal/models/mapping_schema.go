@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Directions a MappingSchema validates mapped output in
+const (
+	// SchemaForward validates Transform output, the platform payload
+	SchemaForward = "forward"
+	// SchemaReverse validates ReverseTransform output, the MercurJS payload
+	SchemaReverse = "reverse"
+)
+
+// MappingSchema is the JSON Schema the mapped output of a platform/entity must
+// match in one direction
+type MappingSchema struct {
+	PlatformID string
+	EntityType string
+	Direction  string
+	Schema     json.RawMessage
+	UpdatedAt  time.Time
+}
+
+// IsValidSchemaDirection reports whether direction is a known schema direction
+func IsValidSchemaDirection(direction string) bool {
+	return direction == SchemaForward || direction == SchemaReverse
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MappingVersion is an immutable, published snapshot of the field mappings of
+// a platform/entity, across all of its shops
+type MappingVersion struct {
+	ID         string
+	PlatformID string
+	EntityType string
+	Version    int
+	Note       string
+	// MappingCount is the number of mappings in the snapshot
+	MappingCount int
+	// Mappings is only loaded when a single version is requested
+	Mappings  []*FieldMapping
+	CreatedAt time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PIIPolicy lists the output paths of a platform whose values are masked,
+// hashed or redacted after every Transform, whatever the entity and whether
+// or not a mapping writes them
+type PIIPolicy struct {
+	PlatformID string
+	Rules      []PIIRule
+	UpdatedAt  time.Time
+}
+
+// PIIRule applies a transform pipeline, e.g. `mask_email`, to the values at a
+// path, e.g. `customer.email` or `addresses.*.phone`
+type PIIRule struct {
+	Path      string
+	Transform string
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type ExchangeRateRepository struct {
+	db *sql.DB
+}
+
+func NewExchangeRateRepository(db *sql.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+func (r *ExchangeRateRepository) List(baseCurrency, quoteCurrency string) ([]*models.ExchangeRate, error) {
+	query := `
+		SELECT base_currency, quote_currency, rate::TEXT, effective_at, created_at
+		FROM exchange_rates
+		WHERE ($1 = '' OR base_currency = $1)
+		  AND ($2 = '' OR quote_currency = $2)
+		ORDER BY base_currency, quote_currency, effective_at DESC
+	`
+
+	return r.query(query, baseCurrency, quoteCurrency)
+}
+
+// FindEffective returns the latest rate for a currency pair that is in effect
+// at the given time, or nil if there is none
+func (r *ExchangeRateRepository) FindEffective(baseCurrency, quoteCurrency string, at time.Time) (*models.ExchangeRate, error) {
+	query := `
+		SELECT base_currency, quote_currency, rate::TEXT, effective_at, created_at
+		FROM exchange_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= $3
+		ORDER BY effective_at DESC
+		LIMIT 1
+	`
+
+	rate, err := scanExchangeRate(r.db.QueryRow(query, baseCurrency, quoteCurrency, at))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rate, nil
+}
+
+// Import upserts a batch of rates in one transaction. A rate for an existing
+// pair and effective time replaces the stored one.
+func (r *ExchangeRateRepository) Import(rates []*models.ExchangeRate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err := tx.Exec(`
+			INSERT INTO exchange_rates (base_currency, quote_currency, rate, effective_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (base_currency, quote_currency, effective_at)
+			DO UPDATE SET rate = EXCLUDED.rate
+		`, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate, rate.EffectiveAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ExchangeRateRepository) query(query string, args ...interface{}) ([]*models.ExchangeRate, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []*models.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+func scanExchangeRate(row rowScanner) (*models.ExchangeRate, error) {
+	rate := &models.ExchangeRate{}
+	err := row.Scan(&rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate, &rate.EffectiveAt, &rate.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
@@ -3,9 +3,12 @@ package repository
 import (
 	"database/sql"
 
+	"github.com/lib/pq"
 	"github.com/mercurjs/adapter/internal/models"
 )
 
+const fieldMappingColumns = `id, platform_id, shop_id, entity_type, source_field, target_field, transform, split_pattern, condition, default_value, required, unset, is_active, created_at`
+
 type FieldMappingRepository struct {
 	db *sql.DB
 }
@@ -14,135 +17,218 @@ func NewFieldMappingRepository(db *sql.DB) *FieldMappingRepository {
 	return &FieldMappingRepository{db: db}
 }
 
-func (r *FieldMappingRepository) FindByPlatformAndEntity(platformID, entityType string) ([]*models.FieldMapping, error) {
+// FindPublished returns the active mappings of one scope, a shop of the
+// platform or the platform-wide mappings when shopID is "", from the latest
+// published version. Nothing is returned if the set was never published.
+func (r *FieldMappingRepository) FindPublished(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
 	query := `
-		SELECT id, platform_id, entity_type, source_field, target_field, transform, is_active, created_at
-		FROM field_mappings
-		WHERE platform_id = $1 AND entity_type = $2 AND is_active = true
+		SELECT ` + versionMappingColumns + `
+		FROM ` + versionMappingsFrom + `
+		WHERE v.platform_id = $1 AND v.entity_type = $2
+		  AND v.version = (SELECT MAX(version) FROM mapping_versions WHERE platform_id = $1 AND entity_type = $2)
+		  AND m.shop_id = $3 AND m.is_active = true
+		ORDER BY e.position
 	`
 
-	rows, err := r.db.Query(query, platformID, entityType)
+	rows, err := r.db.Query(query, platformID, entityType, shopID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var mappings []*models.FieldMapping
-	for rows.Next() {
-		m := &models.FieldMapping{}
-		var transform sql.NullString
-
-		err := rows.Scan(
-			&m.ID,
-			&m.PlatformID,
-			&m.EntityType,
-			&m.SourceField,
-			&m.TargetField,
-			&transform,
-			&m.IsActive,
-			&m.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
+	return scanFieldMappings(rows)
+}
 
-		if transform.Valid {
-			m.Transform = transform.String
-		}
-		mappings = append(mappings, m)
+// FindByPlatformAndEntity returns the active draft mappings of one scope: a
+// shop of the platform, or the platform-wide mappings when shopID is ""
+func (r *FieldMappingRepository) FindByPlatformAndEntity(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
+	query := `
+		SELECT ` + fieldMappingColumns + `
+		FROM field_mappings
+		WHERE platform_id = $1 AND shop_id = $2 AND entity_type = $3 AND is_active = true
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(query, platformID, shopID, entityType)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return mappings, nil
+	return scanFieldMappings(rows)
 }
 
-func (r *FieldMappingRepository) List(platformID, entityType string) ([]*models.FieldMapping, error) {
+func (r *FieldMappingRepository) List(platformID, shopID, entityType string) ([]*models.FieldMapping, error) {
 	query := `
-		SELECT id, platform_id, entity_type, source_field, target_field, transform, is_active, created_at
+		SELECT ` + fieldMappingColumns + `
 		FROM field_mappings
 		WHERE ($1 = '' OR platform_id = $1)
-		  AND ($2 = '' OR entity_type = $2)
-		ORDER BY platform_id, entity_type, source_field
+		  AND ($2 = '' OR shop_id = $2)
+		  AND ($3 = '' OR entity_type = $3)
+		ORDER BY platform_id, shop_id, entity_type, source_field
 	`
 
-	rows, err := r.db.Query(query, platformID, entityType)
+	rows, err := r.db.Query(query, platformID, shopID, entityType)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var mappings []*models.FieldMapping
-	for rows.Next() {
-		m := &models.FieldMapping{}
-		var transform sql.NullString
-
-		err := rows.Scan(
-			&m.ID,
-			&m.PlatformID,
-			&m.EntityType,
-			&m.SourceField,
-			&m.TargetField,
-			&transform,
-			&m.IsActive,
-			&m.CreatedAt,
-		)
+	return scanFieldMappings(rows)
+}
+
+// FindByPlatform returns every draft mapping of a platform, active or not, in
+// the order they apply within each entity type and shop
+func (r *FieldMappingRepository) FindByPlatform(platformID string) ([]*models.FieldMapping, error) {
+	query := `
+		SELECT ` + fieldMappingColumns + `
+		FROM field_mappings
+		WHERE platform_id = $1
+		ORDER BY entity_type, shop_id, created_at, id
+	`
+
+	rows, err := r.db.Query(query, platformID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFieldMappings(rows)
+}
+
+func (r *FieldMappingRepository) Upsert(mapping *models.FieldMapping) (*models.FieldMapping, error) {
+	return upsertFieldMapping(r.db, mapping)
+}
+
+// ImportPlatform upserts a platform's mappings in one transaction. With
+// prune, the platform's mappings that are not in the set are deleted.
+func (r *FieldMappingRepository) ImportPlatform(platformID string, mappings []*models.FieldMapping, prune bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := []string{}
+	for _, mapping := range mappings {
+		row, err := upsertFieldMapping(tx, mapping)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		ids = append(ids, row.ID)
+	}
 
-		if transform.Valid {
-			m.Transform = transform.String
+	if prune {
+		_, err := tx.Exec(`
+			DELETE FROM field_mappings
+			WHERE platform_id = $1 AND NOT (id = ANY($2::uuid[]))
+		`, platformID, pq.Array(ids))
+		if err != nil {
+			return err
 		}
-		mappings = append(mappings, m)
 	}
 
-	return mappings, nil
+	return tx.Commit()
 }
 
-func (r *FieldMappingRepository) Upsert(mapping *models.FieldMapping) (*models.FieldMapping, error) {
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// upsertFieldMapping saves a mapping. created_at uses the clock rather than
+// the transaction start, so mappings saved in one transaction keep their order.
+func upsertFieldMapping(q queryRower, mapping *models.FieldMapping) (*models.FieldMapping, error) {
 	query := `
-		INSERT INTO field_mappings (platform_id, entity_type, source_field, target_field, transform, is_active)
-		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
-		ON CONFLICT (platform_id, entity_type, source_field)
+		INSERT INTO field_mappings (platform_id, shop_id, entity_type, source_field, target_field, transform, split_pattern, condition, default_value, required, unset, is_active, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12, clock_timestamp())
+		ON CONFLICT (platform_id, shop_id, entity_type, source_field, (COALESCE(condition, '')))
 		DO UPDATE SET
 			target_field = EXCLUDED.target_field,
 			transform = EXCLUDED.transform,
+			split_pattern = EXCLUDED.split_pattern,
+			default_value = EXCLUDED.default_value,
+			required = EXCLUDED.required,
+			unset = EXCLUDED.unset,
 			is_active = EXCLUDED.is_active
-		RETURNING id, platform_id, entity_type, source_field, target_field, transform, is_active, created_at
-	`
-
-	row := &models.FieldMapping{}
-	var transform sql.NullString
+		RETURNING ` + fieldMappingColumns
 
-	err := r.db.QueryRow(
+	return scanFieldMapping(q.QueryRow(
 		query,
 		mapping.PlatformID,
+		mapping.ShopID,
 		mapping.EntityType,
 		mapping.SourceField,
 		mapping.TargetField,
 		mapping.Transform,
+		mapping.SplitPattern,
+		mapping.Condition,
+		mapping.DefaultValue,
+		mapping.Required,
+		mapping.Unset,
 		mapping.IsActive,
-	).Scan(
-		&row.ID,
-		&row.PlatformID,
-		&row.EntityType,
-		&row.SourceField,
-		&row.TargetField,
+	))
+}
+
+func (r *FieldMappingRepository) DeleteByID(id string) error {
+	_, err := r.db.Exec(`DELETE FROM field_mappings WHERE id = $1`, id)
+	return err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFieldMapping(row rowScanner) (*models.FieldMapping, error) {
+	m := &models.FieldMapping{}
+	var transform, splitPattern, condition, defaultValue sql.NullString
+
+	err := row.Scan(
+		&m.ID,
+		&m.PlatformID,
+		&m.ShopID,
+		&m.EntityType,
+		&m.SourceField,
+		&m.TargetField,
 		&transform,
-		&row.IsActive,
-		&row.CreatedAt,
+		&splitPattern,
+		&condition,
+		&defaultValue,
+		&m.Required,
+		&m.Unset,
+		&m.IsActive,
+		&m.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	if transform.Valid {
-		row.Transform = transform.String
+		m.Transform = transform.String
+	}
+	if splitPattern.Valid {
+		m.SplitPattern = splitPattern.String
+	}
+	if condition.Valid {
+		m.Condition = condition.String
+	}
+	if defaultValue.Valid {
+		m.DefaultValue = defaultValue.String
 	}
 
-	return row, nil
+	return m, nil
 }
 
-func (r *FieldMappingRepository) DeleteByID(id string) error {
-	_, err := r.db.Exec(`DELETE FROM field_mappings WHERE id = $1`, id)
-	return err
+func scanFieldMappings(rows *sql.Rows) ([]*models.FieldMapping, error) {
+	var mappings []*models.FieldMapping
+	for rows.Next() {
+		m, err := scanFieldMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+
+	return mappings, rows.Err()
 }
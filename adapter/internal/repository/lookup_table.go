@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type LookupTableRepository struct {
+	db *sql.DB
+}
+
+func NewLookupTableRepository(db *sql.DB) *LookupTableRepository {
+	return &LookupTableRepository{db: db}
+}
+
+func (r *LookupTableRepository) List() ([]*models.LookupTable, error) {
+	query := `
+		SELECT id, name, default_value, reverse_default_value, created_at, updated_at
+		FROM lookup_tables
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []*models.LookupTable
+	for rows.Next() {
+		t, err := scanLookupTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range tables {
+		if t.Entries, err = r.findEntries(t.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return tables, nil
+}
+
+func (r *LookupTableRepository) FindByName(name string) (*models.LookupTable, error) {
+	query := `
+		SELECT id, name, default_value, reverse_default_value, created_at, updated_at
+		FROM lookup_tables
+		WHERE name = $1
+	`
+
+	t, err := scanLookupTable(r.db.QueryRow(query, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Entries, err = r.findEntries(t.ID); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Upsert creates or replaces a lookup table together with all of its entries
+func (r *LookupTableRepository) Upsert(table *models.LookupTable) (*models.LookupTable, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO lookup_tables (name, default_value, reverse_default_value)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name)
+		DO UPDATE SET
+			default_value = EXCLUDED.default_value,
+			reverse_default_value = EXCLUDED.reverse_default_value,
+			updated_at = NOW()
+		RETURNING id, name, default_value, reverse_default_value, created_at, updated_at
+	`
+
+	row, err := scanLookupTable(tx.QueryRow(query, table.Name, table.DefaultValue, table.ReverseDefaultValue))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM lookup_entries WHERE lookup_table_id = $1`, row.ID); err != nil {
+		return nil, err
+	}
+
+	for i, e := range table.Entries {
+		_, err := tx.Exec(
+			`INSERT INTO lookup_entries (lookup_table_id, position, source_value, target_value) VALUES ($1, $2, $3, $4)`,
+			row.ID, i, e.Source, e.Target,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	row.Entries = table.Entries
+	return row, nil
+}
+
+func (r *LookupTableRepository) DeleteByName(name string) error {
+	_, err := r.db.Exec(`DELETE FROM lookup_tables WHERE name = $1`, name)
+	return err
+}
+
+func (r *LookupTableRepository) findEntries(tableID string) ([]models.LookupEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT source_value, target_value
+		FROM lookup_entries
+		WHERE lookup_table_id = $1
+		ORDER BY position
+	`, tableID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LookupEntry
+	for rows.Next() {
+		var e models.LookupEntry
+		if err := rows.Scan(&e.Source, &e.Target); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func scanLookupTable(row rowScanner) (*models.LookupTable, error) {
+	t := &models.LookupTable{}
+	var defaultValue, reverseDefault sql.NullString
+
+	err := row.Scan(&t.ID, &t.Name, &defaultValue, &reverseDefault, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultValue.Valid {
+		t.DefaultValue = &defaultValue.String
+	}
+	if reverseDefault.Valid {
+		t.ReverseDefaultValue = &reverseDefault.String
+	}
+
+	return t, nil
+}
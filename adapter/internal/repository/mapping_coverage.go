@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type MappingCoverageRepository struct {
+	db *sql.DB
+}
+
+func NewMappingCoverageRepository(db *sql.DB) *MappingCoverageRepository {
+	return &MappingCoverageRepository{db: db}
+}
+
+// Record adds a batch of samples of a platform/entity in one transaction.
+// Counts are added to the stored ones and the example is replaced.
+func (r *MappingCoverageRepository) Record(samples *models.CoverageSamples, paths []*models.CoveragePath) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO mapping_coverage_samples (platform_id, entity_type, samples)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (platform_id, entity_type)
+		DO UPDATE SET
+			samples = mapping_coverage_samples.samples + EXCLUDED.samples,
+			updated_at = NOW()
+	`, samples.PlatformID, samples.EntityType, samples.Samples)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		_, err := tx.Exec(`
+			INSERT INTO mapping_coverage (platform_id, entity_type, path, seen_count, mapped_count, example)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (platform_id, entity_type, path)
+			DO UPDATE SET
+				seen_count = mapping_coverage.seen_count + EXCLUDED.seen_count,
+				mapped_count = mapping_coverage.mapped_count + EXCLUDED.mapped_count,
+				example = EXCLUDED.example,
+				last_seen_at = NOW()
+		`, samples.PlatformID, samples.EntityType, path.Path, path.SeenCount, path.MappedCount, path.Example)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListSamples returns the sample counts of a platform's entities, or of one
+// entity when entityType is set
+func (r *MappingCoverageRepository) ListSamples(platformID, entityType string) ([]*models.CoverageSamples, error) {
+	query := `
+		SELECT platform_id, entity_type, samples, updated_at
+		FROM mapping_coverage_samples
+		WHERE platform_id = $1 AND ($2 = '' OR entity_type = $2)
+		ORDER BY entity_type
+	`
+
+	rows, err := r.db.Query(query, platformID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []*models.CoverageSamples
+	for rows.Next() {
+		s := &models.CoverageSamples{}
+		if err := rows.Scan(&s.PlatformID, &s.EntityType, &s.Samples, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+
+	return samples, rows.Err()
+}
+
+// ListPaths returns the paths seen for a platform's entities, or for one
+// entity when entityType is set, most frequent first
+func (r *MappingCoverageRepository) ListPaths(platformID, entityType string) ([]*models.CoveragePath, error) {
+	query := `
+		SELECT platform_id, entity_type, path, seen_count, mapped_count, example, first_seen_at, last_seen_at
+		FROM mapping_coverage
+		WHERE platform_id = $1 AND ($2 = '' OR entity_type = $2)
+		ORDER BY entity_type, seen_count DESC, path
+	`
+
+	rows, err := r.db.Query(query, platformID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []*models.CoveragePath
+	for rows.Next() {
+		p := &models.CoveragePath{}
+		err := rows.Scan(&p.PlatformID, &p.EntityType, &p.Path, &p.SeenCount, &p.MappedCount, &p.Example, &p.FirstSeenAt, &p.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+
+	return paths, rows.Err()
+}
+
+// Delete drops the coverage of a platform's entities, or of one entity when
+// entityType is set
+func (r *MappingCoverageRepository) Delete(platformID, entityType string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mapping_coverage WHERE platform_id = $1 AND ($2 = '' OR entity_type = $2)`, platformID, entityType); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM mapping_coverage_samples WHERE platform_id = $1 AND ($2 = '' OR entity_type = $2)`, platformID, entityType); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
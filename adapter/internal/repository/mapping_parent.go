@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type MappingParentRepository struct {
+	db *sql.DB
+}
+
+func NewMappingParentRepository(db *sql.DB) *MappingParentRepository {
+	return &MappingParentRepository{db: db}
+}
+
+// FindParent returns the parent platform of platformID, or "" if it has none
+func (r *MappingParentRepository) FindParent(platformID string) (string, error) {
+	var parent string
+	err := r.db.QueryRow(`SELECT parent_platform_id FROM mapping_parents WHERE platform_id = $1`, platformID).Scan(&parent)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return parent, err
+}
+
+func (r *MappingParentRepository) List() ([]*models.MappingParent, error) {
+	rows, err := r.db.Query(`
+		SELECT platform_id, parent_platform_id, updated_at
+		FROM mapping_parents
+		ORDER BY platform_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []*models.MappingParent
+	for rows.Next() {
+		p := &models.MappingParent{}
+		if err := rows.Scan(&p.PlatformID, &p.ParentPlatformID, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		parents = append(parents, p)
+	}
+
+	return parents, rows.Err()
+}
+
+func (r *MappingParentRepository) Upsert(parent *models.MappingParent) (*models.MappingParent, error) {
+	query := `
+		INSERT INTO mapping_parents (platform_id, parent_platform_id)
+		VALUES ($1, $2)
+		ON CONFLICT (platform_id)
+		DO UPDATE SET
+			parent_platform_id = EXCLUDED.parent_platform_id,
+			updated_at = NOW()
+		RETURNING platform_id, parent_platform_id, updated_at
+	`
+
+	row := &models.MappingParent{}
+	err := r.db.QueryRow(query, parent.PlatformID, parent.ParentPlatformID).Scan(&row.PlatformID, &row.ParentPlatformID, &row.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (r *MappingParentRepository) Delete(platformID string) error {
+	_, err := r.db.Exec(`DELETE FROM mapping_parents WHERE platform_id = $1`, platformID)
+	return err
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type MappingPolicyRepository struct {
+	db *sql.DB
+}
+
+func NewMappingPolicyRepository(db *sql.DB) *MappingPolicyRepository {
+	return &MappingPolicyRepository{db: db}
+}
+
+func (r *MappingPolicyRepository) Find(platformID, entityType string) (*models.MappingPolicy, error) {
+	query := `
+		SELECT platform_id, entity_type, unmapped_fields, except_paths, updated_at
+		FROM mapping_policies
+		WHERE platform_id = $1 AND entity_type = $2
+	`
+
+	policy, err := scanMappingPolicy(r.db.QueryRow(query, platformID, entityType))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (r *MappingPolicyRepository) List(platformID string) ([]*models.MappingPolicy, error) {
+	query := `
+		SELECT platform_id, entity_type, unmapped_fields, except_paths, updated_at
+		FROM mapping_policies
+		WHERE ($1 = '' OR platform_id = $1)
+		ORDER BY platform_id, entity_type
+	`
+
+	rows, err := r.db.Query(query, platformID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.MappingPolicy
+	for rows.Next() {
+		policy, err := scanMappingPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *MappingPolicyRepository) Upsert(policy *models.MappingPolicy) (*models.MappingPolicy, error) {
+	query := `
+		INSERT INTO mapping_policies (platform_id, entity_type, unmapped_fields, except_paths)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (platform_id, entity_type)
+		DO UPDATE SET
+			unmapped_fields = EXCLUDED.unmapped_fields,
+			except_paths = EXCLUDED.except_paths,
+			updated_at = NOW()
+		RETURNING platform_id, entity_type, unmapped_fields, except_paths, updated_at
+	`
+
+	return scanMappingPolicy(r.db.QueryRow(
+		query,
+		policy.PlatformID,
+		policy.EntityType,
+		policy.UnmappedFields,
+		pq.StringArray(policy.ExceptPaths),
+	))
+}
+
+func (r *MappingPolicyRepository) Delete(platformID, entityType string) error {
+	_, err := r.db.Exec(`DELETE FROM mapping_policies WHERE platform_id = $1 AND entity_type = $2`, platformID, entityType)
+	return err
+}
+
+func scanMappingPolicy(row rowScanner) (*models.MappingPolicy, error) {
+	policy := &models.MappingPolicy{}
+	var exceptPaths pq.StringArray
+
+	err := row.Scan(
+		&policy.PlatformID,
+		&policy.EntityType,
+		&policy.UnmappedFields,
+		&exceptPaths,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.ExceptPaths = []string(exceptPaths)
+	return policy, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type MappingSchemaRepository struct {
+	db *sql.DB
+}
+
+func NewMappingSchemaRepository(db *sql.DB) *MappingSchemaRepository {
+	return &MappingSchemaRepository{db: db}
+}
+
+func (r *MappingSchemaRepository) Find(platformID, entityType, direction string) (*models.MappingSchema, error) {
+	query := `
+		SELECT platform_id, entity_type, direction, schema, updated_at
+		FROM mapping_schemas
+		WHERE platform_id = $1 AND entity_type = $2 AND direction = $3
+	`
+
+	schema, err := scanMappingSchema(r.db.QueryRow(query, platformID, entityType, direction))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func (r *MappingSchemaRepository) List(platformID string) ([]*models.MappingSchema, error) {
+	query := `
+		SELECT platform_id, entity_type, direction, schema, updated_at
+		FROM mapping_schemas
+		WHERE ($1 = '' OR platform_id = $1)
+		ORDER BY platform_id, entity_type, direction
+	`
+
+	rows, err := r.db.Query(query, platformID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []*models.MappingSchema
+	for rows.Next() {
+		schema, err := scanMappingSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, rows.Err()
+}
+
+func (r *MappingSchemaRepository) Upsert(schema *models.MappingSchema) (*models.MappingSchema, error) {
+	query := `
+		INSERT INTO mapping_schemas (platform_id, entity_type, direction, schema)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (platform_id, entity_type, direction)
+		DO UPDATE SET
+			schema = EXCLUDED.schema,
+			updated_at = NOW()
+		RETURNING platform_id, entity_type, direction, schema, updated_at
+	`
+
+	return scanMappingSchema(r.db.QueryRow(
+		query,
+		schema.PlatformID,
+		schema.EntityType,
+		schema.Direction,
+		string(schema.Schema),
+	))
+}
+
+func (r *MappingSchemaRepository) Delete(platformID, entityType, direction string) error {
+	_, err := r.db.Exec(`DELETE FROM mapping_schemas WHERE platform_id = $1 AND entity_type = $2 AND direction = $3`, platformID, entityType, direction)
+	return err
+}
+
+func scanMappingSchema(row rowScanner) (*models.MappingSchema, error) {
+	schema := &models.MappingSchema{}
+	var doc []byte
+
+	err := row.Scan(
+		&schema.PlatformID,
+		&schema.EntityType,
+		&schema.Direction,
+		&doc,
+		&schema.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	schema.Schema = doc
+	return schema, nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+// versionMappingsFrom expands the JSONB snapshot of a mapping_versions row
+// (aliased v) into field_mappings rows (aliased m). e.position keeps the
+// order they were published in.
+const versionMappingsFrom = `mapping_versions v
+	CROSS JOIN LATERAL jsonb_array_elements(v.mappings) WITH ORDINALITY AS e(doc, position)
+	CROSS JOIN LATERAL jsonb_populate_record(NULL::field_mappings, e.doc) AS m`
+
+// versionMappingColumns selects fieldMappingColumns from versionMappingsFrom.
+// Snapshots taken before a column existed have no value for it, hence the
+// defaults.
+const versionMappingColumns = `m.id, m.platform_id, COALESCE(m.shop_id, ''), m.entity_type, m.source_field, m.target_field, m.transform, m.split_pattern, m.condition, m.default_value, COALESCE(m.required, false), COALESCE(m.unset, false), COALESCE(m.is_active, true), m.created_at`
+
+const mappingVersionColumns = `id, platform_id, entity_type, version, COALESCE(note, ''), jsonb_array_length(mappings), created_at`
+
+type MappingVersionRepository struct {
+	db *sql.DB
+}
+
+func NewMappingVersionRepository(db *sql.DB) *MappingVersionRepository {
+	return &MappingVersionRepository{db: db}
+}
+
+// Publish snapshots the current draft mappings of a platform/entity as the
+// next version
+func (r *MappingVersionRepository) Publish(platformID, entityType, note string) (*models.MappingVersion, error) {
+	snapshot := `
+		SELECT COALESCE(jsonb_agg(to_jsonb(f) ORDER BY created_at, id), '[]')
+		FROM field_mappings f
+		WHERE platform_id = $1 AND entity_type = $2
+	`
+	return r.insert(platformID, entityType, note, snapshot)
+}
+
+// Rollback publishes the mappings of an earlier version again as the next
+// version. It returns nil if that version does not exist.
+func (r *MappingVersionRepository) Rollback(platformID, entityType string, version int, note string) (*models.MappingVersion, error) {
+	snapshot := `
+		SELECT mappings
+		FROM mapping_versions
+		WHERE platform_id = $1 AND entity_type = $2 AND version = $4
+	`
+	row, err := r.insert(platformID, entityType, note, snapshot, version)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return row, err
+}
+
+// insert adds the next version of a platform/entity with the mappings
+// selected by snapshotQuery, which gets platformID, entityType, note and
+// extra as $1, $2, $3, $4...
+func (r *MappingVersionRepository) insert(platformID, entityType, note, snapshotQuery string, extra ...interface{}) (*models.MappingVersion, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize publishes of the same set so version numbers stay sequential
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))`, platformID, entityType); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO mapping_versions (platform_id, entity_type, version, mappings, note)
+		SELECT $1, $2,
+			COALESCE((SELECT MAX(version) FROM mapping_versions WHERE platform_id = $1 AND entity_type = $2), 0) + 1,
+			snapshot.mappings, NULLIF($3, '')
+		FROM (` + snapshotQuery + `) AS snapshot(mappings)
+		RETURNING ` + mappingVersionColumns
+
+	args := append([]interface{}{platformID, entityType, note}, extra...)
+	row, err := scanMappingVersion(tx.QueryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// List returns the version history of a platform/entity, newest first,
+// without the mappings themselves
+func (r *MappingVersionRepository) List(platformID, entityType string) ([]*models.MappingVersion, error) {
+	query := `
+		SELECT ` + mappingVersionColumns + `
+		FROM mapping_versions
+		WHERE platform_id = $1 AND entity_type = $2
+		ORDER BY version DESC
+	`
+
+	rows, err := r.db.Query(query, platformID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.MappingVersion
+	for rows.Next() {
+		v, err := scanMappingVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+// Find returns one version with its mappings, or nil if it does not exist
+func (r *MappingVersionRepository) Find(platformID, entityType string, version int) (*models.MappingVersion, error) {
+	query := `
+		SELECT ` + mappingVersionColumns + `
+		FROM mapping_versions
+		WHERE platform_id = $1 AND entity_type = $2 AND version = $3
+	`
+
+	v, err := scanMappingVersion(r.db.QueryRow(query, platformID, entityType, version))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+versionMappingColumns+`
+		FROM `+versionMappingsFrom+`
+		WHERE v.id = $1
+		ORDER BY e.position
+	`, v.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if v.Mappings, err = scanFieldMappings(rows); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func scanMappingVersion(row rowScanner) (*models.MappingVersion, error) {
+	v := &models.MappingVersion{}
+	err := row.Scan(&v.ID, &v.PlatformID, &v.EntityType, &v.Version, &v.Note, &v.MappingCount, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
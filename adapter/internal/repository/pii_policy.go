@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/mercurjs/adapter/internal/models"
+)
+
+type PIIPolicyRepository struct {
+	db *sql.DB
+}
+
+func NewPIIPolicyRepository(db *sql.DB) *PIIPolicyRepository {
+	return &PIIPolicyRepository{db: db}
+}
+
+func (r *PIIPolicyRepository) Find(platformID string) (*models.PIIPolicy, error) {
+	query := `
+		SELECT platform_id, rules, updated_at
+		FROM pii_policies
+		WHERE platform_id = $1
+	`
+
+	policy, err := scanPIIPolicy(r.db.QueryRow(query, platformID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (r *PIIPolicyRepository) List() ([]*models.PIIPolicy, error) {
+	query := `
+		SELECT platform_id, rules, updated_at
+		FROM pii_policies
+		ORDER BY platform_id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.PIIPolicy
+	for rows.Next() {
+		policy, err := scanPIIPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *PIIPolicyRepository) Upsert(policy *models.PIIPolicy) (*models.PIIPolicy, error) {
+	rules, err := json.Marshal(policy.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO pii_policies (platform_id, rules)
+		VALUES ($1, $2)
+		ON CONFLICT (platform_id)
+		DO UPDATE SET
+			rules = EXCLUDED.rules,
+			updated_at = NOW()
+		RETURNING platform_id, rules, updated_at
+	`
+
+	return scanPIIPolicy(r.db.QueryRow(query, policy.PlatformID, string(rules)))
+}
+
+func (r *PIIPolicyRepository) Delete(platformID string) error {
+	_, err := r.db.Exec(`DELETE FROM pii_policies WHERE platform_id = $1`, platformID)
+	return err
+}
+
+func scanPIIPolicy(row rowScanner) (*models.PIIPolicy, error) {
+	policy := &models.PIIPolicy{}
+	var rules []byte
+
+	if err := row.Scan(&policy.PlatformID, &rules, &policy.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rules, &policy.Rules); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
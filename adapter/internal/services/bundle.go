@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+// ErrInvalidBundle wraps the validation errors of an imported bundle
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// BundleService exports and imports a platform's draft mappings as bundles
+type BundleService struct {
+	repo   *repository.FieldMappingRepository
+	mapper *mapper.Mapper
+}
+
+func NewBundleService(repo *repository.FieldMappingRepository, fieldMapper *mapper.Mapper) *BundleService {
+	return &BundleService{repo: repo, mapper: fieldMapper}
+}
+
+// Export returns the bundle of a platform's draft mappings
+func (s *BundleService) Export(platformID string) (*mapper.Bundle, error) {
+	mappings, err := s.repo.FindByPlatform(platformID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mappings: %w", err)
+	}
+	return mapper.NewBundle(platformID, mappings)
+}
+
+// Import replaces a platform's drafts with the bundle's mappings and returns
+// the changes per entity type. With dryRun nothing is saved; with prune the
+// platform's drafts missing from the bundle are deleted, whatever their
+// entity type or shop. A bundle whose drafts would have lint errors is
+// rejected, also in a dry run. Drafts go live once published.
+func (s *BundleService) Import(bundle *mapper.Bundle, dryRun, prune bool) (map[string]*mapper.MappingDiff, error) {
+	mappings, err := bundle.FieldMappings()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	current, err := s.repo.FindByPlatform(bundle.PlatformID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mappings: %w", err)
+	}
+
+	for entityType, drafts := range mapper.ImportedMappings(current, mappings, prune) {
+		issues, err := s.mapper.LintDrafts(bundle.PlatformID, entityType, drafts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lint mappings: %w", err)
+		}
+		if conflicts := mapper.LintErrors(issues); len(conflicts) > 0 {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidBundle, entityType, strings.Join(conflicts, "; "))
+		}
+	}
+
+	plan := mapper.PlanBundle(current, mappings, prune)
+	if dryRun {
+		return plan, nil
+	}
+
+	if err := s.repo.ImportPlatform(bundle.PlatformID, mappings, prune); err != nil {
+		return nil, fmt.Errorf("failed to import mappings: %w", err)
+	}
+	return plan, nil
+}
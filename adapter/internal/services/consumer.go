@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -8,12 +9,21 @@ import (
 	"github.com/mercurjs/adapter/internal/api"
 	"github.com/mercurjs/adapter/internal/broker"
 	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
 )
 
+// entityMapper is the part of *mapper.Mapper the consumer uses
+type entityMapper interface {
+	Transform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error)
+	ReverseTransform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error)
+	ValidateOutput(platformID, entityType string, reverse bool, data map[string]interface{}) error
+	MaskPII(platformID string, data map[string]interface{}) (map[string]interface{}, error)
+}
+
 type ConsumerService struct {
 	auth      *AuthService
 	apiClient *api.MercurJSClient
-	mapper    *mapper.Mapper
+	mapper    entityMapper
 }
 
 func resolvePlatformID(req *broker.RequestMessage) string {
@@ -83,7 +93,14 @@ func (s *ConsumerService) handleAPIRequest(req *broker.RequestMessage) *broker.R
 		return errorResponse(req.RequestID, "api_error", err.Error())
 	}
 
-	// Apply field mapping if entity_type is specified
+	return s.mapResult(req, result)
+}
+
+// mapResult applies field mapping to an api_request response when
+// entity_type is specified: to each entity under entity_key, or to the
+// response itself. Whatever is not mapped, because there is nothing to map
+// or mapping failed, still goes through the platform's PII policy.
+func (s *ConsumerService) mapResult(req *broker.RequestMessage, result map[string]interface{}) *broker.ResponseMessage {
 	entityType, hasEntityType := req.Params["entity_type"].(string)
 	entityKey, hasEntityKey := req.Params["entity_key"].(string)
 
@@ -93,29 +110,68 @@ func (s *ConsumerService) handleAPIRequest(req *broker.RequestMessage) *broker.R
 		// Map array of entities
 		if entities, ok := result[entityKey].([]interface{}); ok {
 			var mappedEntities []map[string]interface{}
-			for _, entity := range entities {
+			var missing []string
+			var violations []mapper.SchemaViolation
+			for i, entity := range entities {
 				if entityMap, ok := entity.(map[string]interface{}); ok {
-					mapped, err := s.mapper.Transform(platformID, entityType, entityMap)
-					if err != nil {
-						mappedEntities = append(mappedEntities, entityMap)
-					} else {
-						mappedEntities = append(mappedEntities, mapped)
+					mapped, err := s.mapper.Transform(platformID, req.ShopID, entityType, entityMap)
+					var validationErr *mapper.ValidationError
+					if errors.As(err, &validationErr) {
+						// Report missing fields with their position in the response
+						for _, path := range validationErr.Missing {
+							missing = append(missing, fmt.Sprintf("%s.%d.%s", entityKey, i, path))
+						}
+						continue
+					} else if err != nil {
+						log.Printf("[consumer] Mapping error (%s.%d): %v", entityKey, i, err)
+						if mapped, err = s.mapper.MaskPII(platformID, entityMap); err != nil {
+							return errorResponse(req.RequestID, "mapping_error", err.Error())
+						}
+					}
+					mappedEntities = append(mappedEntities, mapped)
+
+					if schemaErr := s.validateOutput(platformID, entityType, false, mapped); schemaErr != nil {
+						for _, v := range schemaErr.Violations {
+							v.Path = strings.TrimSuffix(fmt.Sprintf("%s.%d.%s", entityKey, i, v.Path), ".")
+							violations = append(violations, v)
+						}
 					}
 				}
 			}
+			if len(missing) > 0 {
+				return validationErrorResponse(req.RequestID, &mapper.ValidationError{Missing: missing})
+			}
+			if len(violations) > 0 {
+				return schemaErrorResponse(req.RequestID, &mapper.SchemaError{Direction: models.SchemaForward, Violations: violations})
+			}
 			result[entityKey] = mappedEntities
+			return successResponse(req.RequestID, result)
 		}
 	} else if hasEntityType && entityType != "" {
 		// Map single entity (the result itself)
-		mapped, err := s.mapper.Transform(platformID, entityType, result)
-		if err != nil {
+		mapped, err := s.mapper.Transform(platformID, req.ShopID, entityType, result)
+		var validationErr *mapper.ValidationError
+		if errors.As(err, &validationErr) {
+			return validationErrorResponse(req.RequestID, validationErr)
+		} else if err != nil {
 			log.Printf("[consumer] Mapping error: %v", err)
-		} else {
-			result = mapped
+			if mapped, err = s.mapper.MaskPII(platformID, result); err != nil {
+				return errorResponse(req.RequestID, "mapping_error", err.Error())
+			}
 		}
+		result = mapped
+
+		if schemaErr := s.validateOutput(platformID, entityType, false, result); schemaErr != nil {
+			return schemaErrorResponse(req.RequestID, schemaErr)
+		}
+		return successResponse(req.RequestID, result)
 	}
 
-	return successResponse(req.RequestID, result)
+	masked, err := s.mapper.MaskPII(platformID, result)
+	if err != nil {
+		return errorResponse(req.RequestID, "mapping_error", err.Error())
+	}
+	return successResponse(req.RequestID, masked)
 }
 
 // handleCreateProduct handles product creation requests from external services
@@ -142,12 +198,17 @@ func (s *ConsumerService) handleCreateProduct(req *broker.RequestMessage) *broke
 
 	// Apply reverse field mapping if entity_type is specified
 	if entityType, ok := req.Params["entity_type"].(string); ok && entityType != "" {
-		mapped, err := s.mapper.ReverseTransform(resolvePlatformID(req), entityType, productData)
+		platformID := resolvePlatformID(req)
+		mapped, err := s.mapper.ReverseTransform(platformID, req.ShopID, entityType, productData)
 		if err != nil {
 			log.Printf("[consumer] Reverse mapping error: %v", err)
 		} else {
 			productData = mapped
 		}
+
+		if schemaErr := s.validateOutput(platformID, entityType, true, productData); schemaErr != nil {
+			return schemaErrorResponse(req.RequestID, schemaErr)
+		}
 	}
 
 	// Call MercurJS API to create product
@@ -161,6 +222,21 @@ func (s *ConsumerService) handleCreateProduct(req *broker.RequestMessage) *broke
 	return successResponse(req.RequestID, result)
 }
 
+// validateOutput checks mapped data against the schema of its platform/entity.
+// Like mapping errors, failures to load the schema are logged and let the
+// data through.
+func (s *ConsumerService) validateOutput(platformID, entityType string, reverse bool, data map[string]interface{}) *mapper.SchemaError {
+	err := s.mapper.ValidateOutput(platformID, entityType, reverse, data)
+	var schemaErr *mapper.SchemaError
+	if errors.As(err, &schemaErr) {
+		return schemaErr
+	}
+	if err != nil {
+		log.Printf("[consumer] Schema validation error: %v", err)
+	}
+	return nil
+}
+
 func successResponse(requestID string, data interface{}) *broker.ResponseMessage {
 	return &broker.ResponseMessage{
 		RequestID: requestID,
@@ -170,6 +246,19 @@ func successResponse(requestID string, data interface{}) *broker.ResponseMessage
 	}
 }
 
+func validationErrorResponse(requestID string, err *mapper.ValidationError) *broker.ResponseMessage {
+	resp := errorResponse(requestID, "validation_error", err.Error())
+	resp.Error.Details = err
+	return resp
+}
+
+// schemaErrorResponse reports each schema violation with its path in Details
+func schemaErrorResponse(requestID string, err *mapper.SchemaError) *broker.ResponseMessage {
+	resp := errorResponse(requestID, "schema_validation_error", err.Error())
+	resp.Error.Details = err
+	return resp
+}
+
 func errorResponse(requestID, code, message string) *broker.ResponseMessage {
 	return &broker.ResponseMessage{
 		RequestID: requestID,
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mercurjs/adapter/internal/broker"
+)
+
+// testMapper maps the entity types in mappings by renaming fields, fails for
+// the others, and masks the email field
+type testMapper struct {
+	mappings map[string]map[string]string
+	piiErr   error
+}
+
+func (m *testMapper) Transform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	fields, ok := m.mappings[entityType]
+	if !ok {
+		return nil, errors.New("mapping " + entityType + ": unknown transform")
+	}
+	out := make(map[string]interface{})
+	for from, to := range fields {
+		if value, ok := data[from]; ok {
+			out[to] = value
+		}
+	}
+	return out, nil
+}
+
+func (m *testMapper) ReverseTransform(platformID, shopID, entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	return data, nil
+}
+
+func (m *testMapper) ValidateOutput(platformID, entityType string, reverse bool, data map[string]interface{}) error {
+	return nil
+}
+
+func (m *testMapper) MaskPII(platformID string, data map[string]interface{}) (map[string]interface{}, error) {
+	if m.piiErr != nil {
+		return nil, m.piiErr
+	}
+	masked := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		masked[key] = value
+	}
+	if _, ok := masked["email"]; ok {
+		masked["email"] = "***"
+	}
+	return masked, nil
+}
+
+func TestMapResultMasksUnmappedPayloads(t *testing.T) {
+	service := &ConsumerService{mapper: &testMapper{
+		mappings: map[string]map[string]string{"seller": {"name": "store_name"}},
+	}}
+
+	seller := func() map[string]interface{} {
+		return map[string]interface{}{"name": "Acme", "email": "owner@example.com"}
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		result map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "mapped entities",
+			params: map[string]interface{}{"entity_type": "seller", "entity_key": "sellers"},
+			result: map[string]interface{}{"sellers": []interface{}{seller()}},
+			want: map[string]interface{}{"sellers": []map[string]interface{}{
+				{"store_name": "Acme"},
+			}},
+		},
+		{
+			name:   "entities whose mapping fails",
+			params: map[string]interface{}{"entity_type": "customer", "entity_key": "sellers"},
+			result: map[string]interface{}{"sellers": []interface{}{seller()}},
+			want: map[string]interface{}{"sellers": []map[string]interface{}{
+				{"name": "Acme", "email": "***"},
+			}},
+		},
+		{
+			name:   "entity whose mapping fails",
+			params: map[string]interface{}{"entity_type": "customer"},
+			result: seller(),
+			want:   map[string]interface{}{"name": "Acme", "email": "***"},
+		},
+		{
+			name:   "entity_key that is not a list",
+			params: map[string]interface{}{"entity_type": "seller", "entity_key": "seller"},
+			result: seller(),
+			want:   map[string]interface{}{"name": "Acme", "email": "***"},
+		},
+		{
+			name:   "no entity_type",
+			params: map[string]interface{}{"path": "/sellers/1"},
+			result: seller(),
+			want:   map[string]interface{}{"name": "Acme", "email": "***"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := service.mapResult(&broker.RequestMessage{RequestID: "req_001", Params: tt.params}, tt.result)
+			if !resp.Success {
+				t.Fatalf("response error = %+v, want success", resp.Error)
+			}
+			if !reflect.DeepEqual(resp.Data, tt.want) {
+				t.Errorf("data = %v, want %v", resp.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapResultFailsWhenPIIPolicyCannotBeLoaded(t *testing.T) {
+	service := &ConsumerService{mapper: &testMapper{piiErr: errors.New("failed to load PII policy")}}
+
+	resp := service.mapResult(&broker.RequestMessage{
+		RequestID: "req_001",
+		Params:    map[string]interface{}{"entity_type": "customer"},
+	}, map[string]interface{}{"email": "owner@example.com"})
+
+	if resp.Success || resp.Error == nil || resp.Error.Code != "mapping_error" {
+		t.Errorf("response = %+v, want a mapping_error instead of the raw payload", resp)
+	}
+	if resp.Data != nil {
+		t.Errorf("data = %v, want none", resp.Data)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mercurjs/adapter/internal/mapper"
+	"github.com/mercurjs/adapter/internal/models"
+	"github.com/mercurjs/adapter/internal/repository"
+)
+
+// maxCoverageExample bounds the stored example of a path
+const maxCoverageExample = 200
+
+// CoverageService collects the payload samples of the mapper in memory and
+// flushes them to the database in batches, so sampling never waits on it
+type CoverageService struct {
+	repo    *repository.MappingCoverageRepository
+	mu      sync.Mutex
+	pending map[string]*pendingCoverage
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// pendingCoverage is the samples of a platform/entity not yet flushed
+type pendingCoverage struct {
+	samples *models.CoverageSamples
+	paths   map[string]*models.CoveragePath
+}
+
+func NewCoverageService(repo *repository.MappingCoverageRepository) *CoverageService {
+	return &CoverageService{
+		repo:    repo,
+		pending: make(map[string]*pendingCoverage),
+	}
+}
+
+// ObserveCoverage adds a sample to the pending batch. It implements
+// mapper.CoverageObserver.
+func (s *CoverageService) ObserveCoverage(sample *mapper.CoverageSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sample.PlatformID + ":" + sample.EntityType
+	batch, ok := s.pending[key]
+	if !ok {
+		batch = &pendingCoverage{
+			samples: &models.CoverageSamples{PlatformID: sample.PlatformID, EntityType: sample.EntityType},
+			paths:   make(map[string]*models.CoveragePath),
+		}
+		s.pending[key] = batch
+	}
+
+	batch.samples.Samples++
+	for _, observed := range sample.Paths {
+		path, ok := batch.paths[observed.Path]
+		if !ok {
+			// One example per path and batch is enough
+			path = &models.CoveragePath{Path: observed.Path, Example: coverageExample(observed.Example)}
+			batch.paths[observed.Path] = path
+		}
+		path.SeenCount++
+		if observed.Mapped {
+			path.MappedCount++
+		}
+	}
+}
+
+// Start flushes the pending samples every interval until Close
+func (s *CoverageService) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("[coverage] Failed to save samples: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic flush and saves what is pending
+func (s *CoverageService) Close() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	if err := s.Flush(); err != nil {
+		log.Printf("[coverage] Failed to save samples: %v", err)
+	}
+}
+
+// Flush saves the pending samples. Batches that fail to save are dropped:
+// coverage is a statistic, and the next samples will fill the gap.
+func (s *CoverageService) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]*pendingCoverage)
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, batch := range pending {
+		paths := make([]*models.CoveragePath, 0, len(batch.paths))
+		for _, path := range batch.paths {
+			paths = append(paths, path)
+		}
+		if err := s.repo.Record(batch.samples, paths); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s/%s: %w", batch.samples.PlatformID, batch.samples.EntityType, err)
+		}
+	}
+	return firstErr
+}
+
+// Report returns the coverage of a platform's entities, or of one entity when
+// entityType is set, including the samples not flushed yet
+func (s *CoverageService) Report(platformID, entityType string) ([]*models.CoverageReport, error) {
+	if err := s.Flush(); err != nil {
+		log.Printf("[coverage] Failed to save samples: %v", err)
+	}
+
+	samples, err := s.repo.ListSamples(platformID, entityType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load samples: %w", err)
+	}
+	paths, err := s.repo.ListPaths(platformID, entityType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load paths: %w", err)
+	}
+
+	reports := make(map[string]*models.CoverageReport, len(samples))
+	result := make([]*models.CoverageReport, 0, len(samples))
+	for _, sample := range samples {
+		report := &models.CoverageReport{
+			PlatformID:      sample.PlatformID,
+			EntityType:      sample.EntityType,
+			Samples:         sample.Samples,
+			Unmapped:        []*models.CoveragePath{},
+			PartiallyMapped: []*models.CoveragePath{},
+			UpdatedAt:       sample.UpdatedAt,
+		}
+		reports[sample.EntityType] = report
+		result = append(result, report)
+	}
+
+	// Paths come most frequent first, so the lists keep that order
+	for _, path := range paths {
+		report, ok := reports[path.EntityType]
+		if !ok {
+			continue
+		}
+		if report.Samples > 0 {
+			path.Frequency = float64(path.SeenCount) / float64(report.Samples)
+		}
+
+		report.SeenPaths++
+		switch {
+		case path.MappedCount == 0:
+			report.Unmapped = append(report.Unmapped, path)
+		case path.MappedCount < path.SeenCount:
+			report.PartiallyMapped = append(report.PartiallyMapped, path)
+			report.MappedPaths++
+		default:
+			report.MappedPaths++
+		}
+	}
+
+	for _, report := range result {
+		if report.SeenPaths > 0 {
+			report.Coverage = float64(report.MappedPaths) / float64(report.SeenPaths)
+		}
+	}
+
+	return result, nil
+}
+
+// Reset drops the coverage of a platform's entities, or of one entity when
+// entityType is set, e.g. after its mappings changed
+func (s *CoverageService) Reset(platformID, entityType string) error {
+	s.mu.Lock()
+	for key, batch := range s.pending {
+		if batch.samples.PlatformID == platformID && (entityType == "" || batch.samples.EntityType == entityType) {
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.repo.Delete(platformID, entityType)
+}
+
+// coverageExample returns the JSON of a value, cut to maxCoverageExample bytes
+func coverageExample(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	if len(data) > maxCoverageExample {
+		cut := maxCoverageExample
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		return string(data[:cut]) + "..."
+	}
+	return string(data)
+}
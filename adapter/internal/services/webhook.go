@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -48,7 +49,10 @@ func (s *webhookService) VerifySignature(payload []byte, signature string) bool
 	return subtle.ConstantTimeCompare([]byte(signature), []byte(expectedSig)) == 1
 }
 
-// ProcessWebhook processes the webhook and publishes to broker
+// ProcessWebhook processes the webhook and publishes to broker.
+// Returns a *mapper.ValidationError when required mapped fields are missing,
+// and a *mapper.SchemaError when the mapped data does not match the platform's
+// schema, in which case the event was published to its quarantine topic.
 func (s *webhookService) ProcessWebhook(eventType string, data map[string]interface{}) error {
 	// Extract platform and shop_id from data
 	platform := extractString(data, "platform", "default")
@@ -68,12 +72,37 @@ func (s *webhookService) ProcessWebhook(eventType string, data map[string]interf
 	mappedData := data
 	entityType := inferEntityType(eventType, data)
 	if s.mapper != nil && entityType != "" {
-		transformed, err := s.mapper.Transform(platformID, entityType, data)
-		if err != nil {
-			log.Printf("[webhook] Mapping failed (platform=%s entity=%s), using raw payload: %v", platformID, entityType, err)
+		transformed, err := s.mapper.Transform(platformID, shopID, entityType, data)
+		var validationErr *mapper.ValidationError
+		if errors.As(err, &validationErr) {
+			return err
+		} else if err != nil {
+			log.Printf("[webhook] Mapping failed (platform=%s shop=%s entity=%s), using raw payload: %v", platformID, shopID, entityType, err)
+			if mappedData, err = s.mapper.MaskPII(platformID, data); err != nil {
+				return err
+			}
 		} else {
 			mappedData = transformed
 		}
+
+		err = s.mapper.ValidateOutput(platformID, entityType, false, mappedData)
+		var schemaErr *mapper.SchemaError
+		if errors.As(err, &schemaErr) {
+			log.Printf("[webhook] Quarantining %s (platform=%s shop=%s entity=%s): %v", eventType, platformID, shopID, entityType, err)
+			if err := s.publisher.PublishQuarantine(platform, shopID, eventType, mappedData, schemaErr); err != nil {
+				return err
+			}
+			return schemaErr
+		} else if err != nil {
+			log.Printf("[webhook] Schema validation failed (platform=%s shop=%s entity=%s), publishing unvalidated: %v", platformID, shopID, entityType, err)
+		}
+	} else if s.mapper != nil {
+		// Unmapped events still go through the platform's PII policy
+		masked, err := s.mapper.MaskPII(platformID, data)
+		if err != nil {
+			return err
+		}
+		mappedData = masked
 	}
 
 	// Publish to broker
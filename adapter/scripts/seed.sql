@@ -16,7 +16,7 @@ VALUES
   ('shopee', 'product', 'id', 'item_id', NULL, true),
   ('shopee', 'product', 'title', 'item_name', NULL, true),
   ('shopee', 'product', 'variants.0.price', 'price', 'cents_to_dollars', true)
-ON CONFLICT (platform_id, entity_type, source_field) DO NOTHING;
+ON CONFLICT DO NOTHING;
 
 -- Seed field mappings for Lazada
 INSERT INTO field_mappings (platform_id, entity_type, source_field, target_field, transform, is_active)
@@ -26,4 +26,24 @@ VALUES
   ('lazada', 'product', 'id', 'sku_id', NULL, true),
   ('lazada', 'product', 'title', 'product_name', NULL, true),
   ('lazada', 'product', 'variants.0.price', 'special_price', 'cents_to_dollars', true)
-ON CONFLICT (platform_id, entity_type, source_field) DO NOTHING;
+ON CONFLICT DO NOTHING;
+
+-- Publish the seeded mappings, as POST /api/mapping-versions/{platform_id}/{entity_type}/publish
+-- would; the mapper only applies published versions. A set whose latest
+-- version already matches its drafts is left alone so re-seeding is a no-op.
+INSERT INTO mapping_versions (platform_id, entity_type, version, mappings, note)
+SELECT d.platform_id, d.entity_type, COALESCE(latest.version, 0) + 1, d.mappings, 'Seed data'
+FROM (
+  SELECT platform_id, entity_type, jsonb_agg(to_jsonb(f) ORDER BY created_at, id) AS mappings
+  FROM field_mappings f
+  WHERE platform_id IN ('shopee', 'lazada')
+  GROUP BY platform_id, entity_type
+) d
+LEFT JOIN LATERAL (
+  SELECT v.version, v.mappings
+  FROM mapping_versions v
+  WHERE v.platform_id = d.platform_id AND v.entity_type = d.entity_type
+  ORDER BY v.version DESC
+  LIMIT 1
+) latest ON true
+WHERE latest.mappings IS DISTINCT FROM d.mappings;
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestSeededMappingsApplied checks that the mappings from scripts/seed.sql are
+// published and applied; run it after make seed
+func TestSeededMappingsApplied(t *testing.T) {
+	fmt.Println("🚀 Starting Seeded Mappings Test")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"platform_id": "shopee",
+		"entity_type": "product",
+		"payload": map[string]interface{}{
+			"id":    "prod_1",
+			"title": "Test Product",
+			"variants": []map[string]interface{}{
+				{"price": 1999},
+			},
+		},
+	})
+
+	resp, err := http.Post(adapterURL+"/api/mappings/preview", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to preview mapping: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Adapter error: %d", resp.StatusCode)
+	}
+
+	var preview struct {
+		Output map[string]interface{} `json:"output"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+
+	checks := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+	}{
+		{"item_id", "prod_1", preview.Output["item_id"]},
+		{"item_name", "Test Product", preview.Output["item_name"]},
+		{"price", 19.99, preview.Output["price"]},
+	}
+
+	allPassed := true
+	for _, check := range checks {
+		status := "✅"
+		if check.expected != check.actual {
+			allPassed = false
+			status = "❌"
+		}
+		fmt.Printf("  %s %s: %v\n", status, check.name, check.actual)
+	}
+
+	if !allPassed {
+		t.Fatalf("Seeded shopee product mappings were not applied: %v", preview.Output)
+	}
+}